@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/magicvegetable/architecture-lab-3/painter/lang"
+)
+
+// formatScripts implements `painter fmt [-clamp] [file...]`: every file (or
+// the standard input when none are given) is printed in canonical form.
+func formatScripts(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	clamp := flags.Bool("clamp", false, "clamp out-of-canvas coordinates instead of rejecting the command")
+	_ = flags.Parse(args)
+
+	parser := lang.Parser{}
+
+	if *clamp {
+		parser.Policy = lang.ClampToCanvas
+	}
+
+	paths := flags.Args()
+
+	if len(paths) == 0 {
+		return parser.Format(os.Stdin, out)
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+
+		err = parser.Format(f, out)
+		f.Close()
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
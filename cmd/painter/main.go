@@ -1,39 +1,155 @@
 package main
 
 import (
+	"flag"
+	"image"
+	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/magicvegetable/architecture-lab-3/painter"
 	"github.com/magicvegetable/architecture-lab-3/painter/lang"
 	"github.com/magicvegetable/architecture-lab-3/ui"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/mouse"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			if err := formatScripts(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "render":
+			if err := renderScripts(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	clamp := flag.Bool("clamp", false, "clamp out-of-canvas coordinates instead of rejecting the command")
+	assets := flag.String("assets", "", "directory with the assets shown by the image command")
+	antialias := flag.Bool("aa", false, "render shapes with anti-aliased edges")
+	debug := flag.Bool("debug", false, "log window events and show the frame rate, the queue and the selection over the scene")
+	aspect := flag.Float64("aspect", 1, "width of the canvas divided by its height")
+	scaling := flag.String("scale", "fit", "how the canvas is put into the window: fit, fill or stretch")
+	bind := flag.String("bind", "", "comma separated key bindings like `Ctrl+Y=undo,X=delete`")
+	flag.Parse()
+
+	scaleMode, ok := painter.ParseScaleMode(*scaling)
+	if !ok {
+		log.Fatalf("no scaling named `%s`, it has to be fit, fill or stretch", *scaling)
+	}
+
+	bindings := painter.DefaultBindings()
+	if err := bindings.Parse(*bind); err != nil {
+		log.Fatal(err)
+	}
+
 	var (
 		pv ui.Visualizer
 
 		opLoop painter.Loop
 		parser lang.Parser
 		clickH painter.ClickHandler
+
+		palette painter.Palette
+
+		hud painter.HUD
 	)
 
 	pv.Title = "Simple painter"
+	pv.Debug = *debug
+
+	if *clamp {
+		parser.Policy = lang.ClampToCanvas
+	}
+
+	gen := painter.Generator{Antialias: *antialias, Aspect: *aspect, Scale: scaleMode}
+	gen.SetAssetDir(*assets)
 
-	gen := painter.Generator{}
+	clickH.GetShapes = gen.Snapshot
+	clickH.GetViewport = func() painter.Viewport {
+		return gen.Viewport(pv.Size())
+	}
+	clickH.GetGroup = gen.GroupShapes
+	clickH.GetGrid = gen.Grid
+	clickH.PostOperation = opLoop.PostOperation
 
-	clickH.GetTFigures = gen.GetTFigures
+	// every line of the palette is parsed on its own, so the commands typed
+	// there neither wait for nor mix with the ones coming over HTTP
+	palette.Parse = func(command string) ([]painter.Operation, error) {
+		p := lang.Parser{Policy: parser.Policy}
+		return p.ParseOperations(strings.NewReader(command + "\nupdate"))
+	}
+	palette.PostOperations = opLoop.PostOperations
+
+	shortcuts := painter.Shortcuts{
+		Bindings:      bindings,
+		Selection:     &clickH,
+		Palette:       &palette,
+		PostOperation: opLoop.PostOperation,
+	}
+
+	gen.Overlays = []painter.DrawableElement{&clickH, &palette}
+
+	hud.GetQueueLength = opLoop.QueueLength
+	hud.GetShapes = gen.Snapshot
+	hud.Selection = &clickH
+
+	if pv.Debug {
+		gen.Overlays = append(gen.Overlays, &hud)
+	}
 
 	opLoop.Gen = &gen
 	opLoop.AddDefaultElements()
 	opLoop.Receiver = &pv
 
 	pv.HandleClick = clickH.Update
+	pv.HandleKey = shortcuts.HandleKey
 	pv.OnScreenReady = opLoop.Start
 	pv.GetTexture = opLoop.Gen.Generate
+
+	if pv.Debug {
+		// the HUD follows the cursor and counts the frames
+		pv.HandleClick = func(e mouse.Event) bool {
+			clickH.Update(e)
+			hud.Track(image.Pt(int(e.X), int(e.Y)))
+			return true
+		}
+
+		pv.GetTexture = func(size image.Point) (screen.Texture, error) {
+			start := time.Now()
+			t, err := gen.Generate(size)
+			hud.Frame(start, time.Since(start))
+			return t, err
+		}
+
+		// frames are not drawn while nothing changes, so the HUD is drawn
+		// again once its rate gets out of date
+		pv.OnScreenReady = func(s screen.Screen) {
+			opLoop.Start(s)
+
+			go func() {
+				for range time.Tick(time.Second) {
+					if hud.Stale() {
+						pv.Update()
+					}
+				}
+			}()
+		}
+	}
 	pv.StopLoop = opLoop.Terminate
 
 	go func() {
 		http.Handle("/", lang.HttpHandler(&opLoop, &parser))
+		http.Handle("/assets", lang.AssetsHandler(&gen))
+		http.Handle("/history", lang.HistoryHandler(&opLoop))
 		_ = http.ListenAndServe("localhost:17000", nil)
 	}()
 
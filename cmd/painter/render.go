@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/magicvegetable/architecture-lab-3/painter"
+	"github.com/magicvegetable/architecture-lab-3/painter/lang"
+)
+
+// renderScripts implements `painter render [-size WxH] [-o file] [-aa] [-clamp] [file...]`:
+// the scripts (or the standard input when none are given) are applied to an
+// empty scene, which is then saved as a PNG image.
+func renderScripts(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	size := flags.String("size", "800x800", "size of the image in pixels, WxH")
+	output := flags.String("o", "scene.png", "path of the image to write")
+	assets := flags.String("assets", "", "directory with the assets shown by the image command")
+	antialias := flags.Bool("aa", false, "render shapes with anti-aliased edges")
+	clamp := flags.Bool("clamp", false, "clamp out-of-canvas coordinates instead of rejecting the command")
+	_ = flags.Parse(args)
+
+	var w, h int
+	if _, err := fmt.Sscanf(*size, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		return fmt.Errorf("wrong size `%s`, expected WxH", *size)
+	}
+
+	var in io.Reader = os.Stdin
+
+	if paths := flags.Args(); len(paths) != 0 {
+		readers := []io.Reader{}
+
+		for _, path := range paths {
+			f, err := os.Open(path)
+
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			readers = append(readers, f, strings.NewReader("\n"))
+		}
+
+		in = io.MultiReader(readers...)
+	}
+
+	parser := lang.Parser{}
+
+	if *clamp {
+		parser.Policy = lang.ClampToCanvas
+	}
+
+	ops, err := parser.ParseOperations(io.MultiReader(in, strings.NewReader("\nupdate")))
+
+	if err != nil {
+		return err
+	}
+
+	gen := painter.Generator{Antialias: *antialias}
+	gen.SetAssetDir(*assets)
+
+	for _, op := range ops {
+		gen.Update(op)
+	}
+
+	f, err := os.Create(*output)
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, gen.RenderImage(image.Pt(w, h)))
+}
@@ -0,0 +1,325 @@
+package painter
+
+import (
+	"image/color"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// AlignEdge tells which sides or centers of shapes are lined up.
+type AlignEdge int
+
+const (
+	AlignLeft AlignEdge = iota
+	AlignCenter
+	AlignRight
+	AlignTop
+	AlignMiddle
+	AlignBottom
+)
+
+var alignEdgeNames = []string{"left", "center", "right", "top", "middle", "bottom"}
+
+func (e AlignEdge) String() string {
+	return alignEdgeNames[e]
+}
+
+func ParseAlignEdge(s string) (AlignEdge, bool) {
+	for i, name := range alignEdgeNames {
+		if name == s {
+			return AlignEdge(i), true
+		}
+	}
+
+	return 0, false
+}
+
+// Axis is the direction shapes are distributed along.
+type Axis int
+
+const (
+	Horizontal Axis = iota
+	Vertical
+)
+
+func (a Axis) String() string {
+	if a == Vertical {
+		return "vertical"
+	}
+
+	return "horizontal"
+}
+
+func ParseAxis(s string) (Axis, bool) {
+	switch s {
+	case "horizontal":
+		return Horizontal, true
+	case "vertical":
+		return Vertical, true
+	}
+
+	return 0, false
+}
+
+// units returns the shapes with the ids together with their groups, every
+// group comes once. The store has to be locked by the caller.
+func (gn *Generator) units(ids []int) (units [][]Shape) {
+	var groups []*group
+	var seen []int
+
+	for _, id := range ids {
+		if g := gn.topGroup(id); g != nil {
+			for _, other := range groups {
+				if other == g {
+					g = nil
+				}
+			}
+
+			if g == nil {
+				continue
+			}
+
+			groups = append(groups, g)
+		} else if containsID(seen, id) {
+			continue
+		}
+
+		seen = append(seen, id)
+
+		if shapes := gn.targets(id); len(shapes) != 0 {
+			units = append(units, shapes)
+		}
+	}
+
+	return
+}
+
+func moveShapes(shapes []Shape, v Point) {
+	for _, sh := range shapes {
+		sh.Move(v)
+	}
+}
+
+func center(r Rectangle) Point {
+	return Point{X: (r.Min.X + r.Max.X) / 2, Y: (r.Min.Y + r.Max.Y) / 2}
+}
+
+// align lines up the edges or the centers of the shapes with the ones of
+// the rectangle around them all, the store has to be locked by the caller.
+func (gn *Generator) align(op Align) {
+	units := gn.units(op.IDs)
+
+	if len(units) < 2 {
+		log.Printf("align needs at least two shapes apart from each other")
+		return
+	}
+
+	bounds := make([]Rectangle, len(units))
+	for i, unit := range units {
+		bounds[i] = boundsOf(unit)
+	}
+
+	all := bounds[0]
+	for _, b := range bounds[1:] {
+		all = all.Union(b)
+	}
+
+	for i, unit := range units {
+		b := bounds[i]
+		var v Point
+
+		switch op.Edge {
+		case AlignLeft:
+			v.X = all.Min.X - b.Min.X
+		case AlignCenter:
+			v.X = center(all).X - center(b).X
+		case AlignRight:
+			v.X = all.Max.X - b.Max.X
+		case AlignTop:
+			v.Y = all.Min.Y - b.Min.Y
+		case AlignMiddle:
+			v.Y = center(all).Y - center(b).Y
+		case AlignBottom:
+			v.Y = all.Max.Y - b.Max.Y
+		}
+
+		moveShapes(unit, v)
+	}
+}
+
+// distribute leaves the outermost shapes in place and moves the others
+// between them, so the gaps between neighbours are equal. The store has to
+// be locked by the caller.
+func (gn *Generator) distribute(op Distribute) {
+	units := gn.units(op.IDs)
+
+	if len(units) < 3 {
+		log.Printf("distribute needs at least three shapes apart from each other")
+		return
+	}
+
+	// along returns the start and the end of r on the axis
+	along := func(r Rectangle) (float64, float64) {
+		if op.Axis == Vertical {
+			return r.Min.Y, r.Max.Y
+		}
+		return r.Min.X, r.Max.X
+	}
+
+	sort.SliceStable(units, func(i, j int) bool {
+		a0, a1 := along(boundsOf(units[i]))
+		b0, b1 := along(boundsOf(units[j]))
+		return a0+a1 < b0+b1
+	})
+
+	first, _ := along(boundsOf(units[0]))
+	_, last := along(boundsOf(units[len(units)-1]))
+
+	sizes := 0.0
+	for _, unit := range units {
+		start, end := along(boundsOf(unit))
+		sizes += end - start
+	}
+
+	gap := (last - first - sizes) / float64(len(units)-1)
+	at := first
+
+	for _, unit := range units {
+		start, end := along(boundsOf(unit))
+		v := Point{X: at - start}
+
+		if op.Axis == Vertical {
+			v = Point{Y: at - start}
+		}
+
+		moveShapes(unit, v)
+		at += end - start + gap
+	}
+}
+
+// GuideColor draws the lines showing where shapes line up while dragged.
+var GuideColor = color.RGBA{R: 0xff, G: 0x00, B: 0xcc, A: 0xff}
+
+// Dragged shapes are pulled to the lines of other shapes this many pixels
+// away.
+const guideReach = 4
+
+// guide is a line across the shapes lined up at a side or a center.
+type guide struct {
+	vertical bool
+	at       float64
+	from, to float64
+}
+
+func (g guide) Draw(c Canvas, vp Viewport) {
+	line := []Point{{X: g.at, Y: g.from}, {X: g.at, Y: g.to}}
+
+	if !g.vertical {
+		line = []Point{{X: g.from, Y: g.at}, {X: g.to, Y: g.at}}
+	}
+
+	Stroke{Color: GuideColor, Width: vp.PixelSize()}.Draw(c, vp, line, false)
+}
+
+// alignGuides returns the offset pulling the box to the nearest sides or
+// centers of the others within the reach, and the guides along the lines
+// the box shares with them after that.
+func alignGuides(box Rectangle, others []Rectangle, reach float64) (Point, []guide) {
+	xs := func(r Rectangle) []float64 { return []float64{r.Min.X, center(r).X, r.Max.X} }
+	ys := func(r Rectangle) []float64 { return []float64{r.Min.Y, center(r).Y, r.Max.Y} }
+
+	pull := func(lines func(Rectangle) []float64) float64 {
+		best := math.Inf(1)
+
+		for _, other := range others {
+			for _, o := range lines(other) {
+				for _, b := range lines(box) {
+					if d := o - b; math.Abs(d) <= reach && math.Abs(d) < math.Abs(best) {
+						best = d
+					}
+				}
+			}
+		}
+
+		if math.IsInf(best, 1) {
+			return 0
+		}
+
+		return best
+	}
+
+	v := Point{X: pull(xs), Y: pull(ys)}
+	box = box.Add(v)
+
+	var guides []guide
+
+	for _, other := range others {
+		span := box.Union(other)
+
+		for _, o := range xs(other) {
+			for _, b := range xs(box) {
+				if math.Abs(o-b) < 1e-9 {
+					guides = append(guides, guide{vertical: true, at: o, from: span.Min.Y, to: span.Max.Y})
+				}
+			}
+		}
+
+		for _, o := range ys(other) {
+			for _, b := range ys(box) {
+				if math.Abs(o-b) < 1e-9 {
+					guides = append(guides, guide{at: o, from: span.Min.X, to: span.Max.X})
+				}
+			}
+		}
+	}
+
+	return v, guides
+}
+
+// Align lines up the shapes with the IDs by the Edge of the rectangle
+// around them, groups are moved as a whole.
+type Align struct {
+	Edge AlignEdge
+	IDs  []int
+}
+
+func (a Align) String() string {
+	return "align " + a.Edge.String() + idList(a.IDs)
+}
+
+func (a Align) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func NewAlign(edge AlignEdge, ids []int) Align {
+	return Align{Edge: edge, IDs: ids}
+}
+
+// Distribute spreads the shapes with the IDs along the Axis with equal
+// gaps between them, groups are moved as a whole.
+type Distribute struct {
+	Axis Axis
+	IDs  []int
+}
+
+func (d Distribute) String() string {
+	return "distribute " + d.Axis.String() + idList(d.IDs)
+}
+
+func (d Distribute) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func NewDistribute(axis Axis, ids []int) Distribute {
+	return Distribute{Axis: axis, IDs: ids}
+}
+
+func idList(ids []int) (s string) {
+	for _, id := range ids {
+		s += " " + strconv.Itoa(id)
+	}
+
+	return
+}
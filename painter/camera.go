@@ -0,0 +1,114 @@
+package painter
+
+import "math"
+
+const (
+	MinZoom = 0.1
+	MaxZoom = 50.0
+
+	// ZoomStep is the magnification of one scroll wheel step.
+	ZoomStep = 1.1
+)
+
+// Camera selects the part of the canvas shown in a viewport: Offset is the
+// canvas point in the top-left corner of the view and Zoom is the
+// magnification, where zero means no magnification at all.
+type Camera struct {
+	Offset Point
+	Zoom   float64
+}
+
+func (cam Camera) zoom() float64 {
+	if cam.Zoom == 0 {
+		return 1
+	}
+
+	return cam.Zoom
+}
+
+// Center returns the canvas point in the middle of the view.
+func (cam Camera) Center() Point {
+	half := 0.5 / cam.zoom()
+	return Point{X: cam.Offset.X + half, Y: cam.Offset.Y + half}
+}
+
+// ZoomAt magnifies the view by factor keeping the canvas point p in place.
+func (cam *Camera) ZoomAt(factor float64, p Point) {
+	zoom := math.Min(math.Max(cam.zoom()*factor, MinZoom), MaxZoom)
+	ratio := cam.zoom() / zoom
+
+	cam.Offset.X = p.X - (p.X-cam.Offset.X)*ratio
+	cam.Offset.Y = p.Y - (p.Y-cam.Offset.Y)*ratio
+	cam.Zoom = zoom
+}
+
+// Pan shifts the picture by v, given in fractions of the view.
+func (cam *Camera) Pan(v Point) {
+	cam.Offset.X -= v.X / cam.zoom()
+	cam.Offset.Y -= v.Y / cam.zoom()
+}
+
+// Fit points the camera at r so that it fills the view.
+func (cam *Camera) Fit(r Rectangle) {
+	side := math.Max(r.Max.X-r.Min.X, r.Max.Y-r.Min.Y) * 1.1
+
+	if side <= 0 {
+		*cam = Camera{}
+		return
+	}
+
+	zoom := math.Min(math.Max(1/side, MinZoom), MaxZoom)
+	half := 0.5 / zoom
+
+	cam.Offset = Point{X: (r.Min.X+r.Max.X)/2 - half, Y: (r.Min.Y+r.Max.Y)/2 - half}
+	cam.Zoom = zoom
+}
+
+type Zoom struct {
+	Factor float64
+	At     *Point
+}
+
+func (z Zoom) String() string {
+	if z.At == nil {
+		return "zoom " + formatFloat(z.Factor)
+	}
+
+	return "zoom " + formatFloat(z.Factor) + " " + formatFloat(z.At.X) + " " + formatFloat(z.At.Y)
+}
+
+func (z Zoom) MarshalText() ([]byte, error) {
+	return []byte(z.String()), nil
+}
+
+// NewZoom magnifies the view by factor around at, or around the middle of
+// the view when at is nil.
+func NewZoom(factor float64, at *Point) Zoom {
+	return Zoom{Factor: factor, At: at}
+}
+
+type Pan struct {
+	Offset Point
+}
+
+func (pn Pan) String() string {
+	return "pan " + formatFloat(pn.Offset.X) + " " + formatFloat(pn.Offset.Y)
+}
+
+func (pn Pan) MarshalText() ([]byte, error) {
+	return []byte(pn.String()), nil
+}
+
+func NewPan(x, y float64) Pan {
+	return Pan{Offset: Point{X: x, Y: y}}
+}
+
+type Fit struct{}
+
+func (Fit) String() string {
+	return "fit"
+}
+
+func (f Fit) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
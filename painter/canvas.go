@@ -0,0 +1,162 @@
+package painter
+
+import "image"
+import "image/color"
+import "image/draw"
+import "golang.org/x/exp/shiny/screen"
+
+// Canvas is a surface elements are drawn onto, screen.Texture is one of them.
+type Canvas interface {
+	Bounds() image.Rectangle
+	Fill(dr image.Rectangle, src color.Color, op draw.Op)
+}
+
+// ImageCanvas draws into an in-memory image, which makes it possible to
+// render a scene without a window.
+type ImageCanvas struct {
+	*image.RGBA
+
+	// Antialias makes shapes blend their edges with what is below instead
+	// of covering whole pixels.
+	Antialias bool
+}
+
+func NewImageCanvas(size image.Point) ImageCanvas {
+	return ImageCanvas{RGBA: image.NewRGBA(image.Rectangle{Max: size})}
+}
+
+func (c ImageCanvas) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(c.RGBA, dr, &image.Uniform{C: src}, image.Point{}, op)
+}
+
+func (c ImageCanvas) FillMask(mask *image.Alpha, src color.Color) {
+	draw.DrawMask(c.RGBA, mask.Bounds(), &image.Uniform{C: src}, image.Point{}, mask, mask.Bounds().Min, draw.Over)
+}
+
+// textureCanvas draws into a texture of the screen, which is needed to
+// upload images into the texture.
+type textureCanvas struct {
+	screen.Texture
+
+	scr screen.Screen
+}
+
+// maskLevels is the number of steps the coverage is rounded to on
+// textures, neighbouring pixels of the same step are blended together.
+const maskLevels = 16
+
+// FillMask blends the mask in rectangles of pixels covered the same way, as
+// textures can not be read back. The coverage is rounded to maskLevels steps,
+// runs of equal steps in a row make spans and spans repeated in the rows
+// below are joined, so edges take a few fills instead of one per pixel.
+func (c textureCanvas) FillMask(mask *image.Alpha, src color.Color) {
+	r, g, b, a := src.RGBA()
+	bounds := mask.Bounds().Intersect(c.Bounds())
+
+	type span struct {
+		minX, maxX, level int
+	}
+
+	var rects []image.Rectangle
+	var levels []int
+
+	// open holds the rectangles reaching the row above by their spans
+	open := map[span]int{}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		next := map[span]int{}
+		start, level := bounds.Min.X, 0
+
+		for x := bounds.Min.X; x <= bounds.Max.X; x++ {
+			l := 0
+			if x < bounds.Max.X {
+				l = (int(mask.AlphaAt(x, y).A)*maskLevels + 0x7f) / 0xff
+			}
+
+			if l == level && x < bounds.Max.X {
+				continue
+			}
+
+			if level > 0 {
+				sp := span{minX: start, maxX: x, level: level}
+
+				if i, ok := open[sp]; ok {
+					rects[i].Max.Y++
+					next[sp] = i
+				} else {
+					next[sp] = len(rects)
+					rects = append(rects, image.Rect(start, y, x, y+1))
+					levels = append(levels, level)
+				}
+			}
+
+			start, level = x, l
+		}
+
+		open = next
+	}
+
+	for i, rect := range rects {
+		if levels[i] == maskLevels {
+			c.Fill(rect, src, draw.Over)
+			continue
+		}
+
+		m := uint32(levels[i] * 0xffff / maskLevels)
+		partial := color.RGBA64{
+			R: uint16(r * m / 0xffff), G: uint16(g * m / 0xffff),
+			B: uint16(b * m / 0xffff), A: uint16(a * m / 0xffff),
+		}
+		c.Fill(rect, partial, draw.Over)
+	}
+}
+
+// antialiased tells whether shapes are drawn onto the canvas with smooth
+// edges.
+func antialiased(c Canvas) bool {
+	ic, ok := c.(ImageCanvas)
+	return ok && ic.Antialias
+}
+
+// maskFiller is implemented by canvases able to blend a coverage mask.
+type maskFiller interface {
+	FillMask(mask *image.Alpha, src color.Color)
+}
+
+// fill clips dr to the canvas before filling it, as screens may drop
+// rectangles that lie far outside of the texture.
+func fill(c Canvas, dr image.Rectangle, src color.Color, op draw.Op) {
+	dr = dr.Intersect(c.Bounds())
+
+	if dr.Empty() {
+		return
+	}
+
+	c.Fill(dr, src, op)
+}
+
+// fillMask paints src through the coverage mask. Canvases unable to blend
+// get the pixels covered at least by half, filled span by span.
+func fillMask(c Canvas, mask *image.Alpha, src color.Color) {
+	if mf, ok := c.(maskFiller); ok {
+		mf.FillMask(mask, src)
+		return
+	}
+
+	bounds := mask.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		start := -1
+
+		for x := bounds.Min.X; x <= bounds.Max.X; x++ {
+			covered := x < bounds.Max.X && mask.AlphaAt(x, y).A >= 0x80
+
+			if covered && start < 0 {
+				start = x
+			} else if !covered && start >= 0 {
+				fill(c, image.Rect(start, y, x, y+1), src, draw.Src)
+				start = -1
+			}
+		}
+	}
+}
@@ -1,95 +1,542 @@
 package painter
 
 import "image"
+import "image/color"
+import "image/draw"
+import "golang.org/x/mobile/event/key"
 import "golang.org/x/mobile/event/mouse"
 
+var (
+	HoverColor     = color.RGBA{R: 0x66, G: 0xaa, B: 0xff, A: 0xff}
+	SelectionColor = color.RGBA{R: 0x00, G: 0x66, B: 0xff, A: 0xff}
+
+	// BandColor fills the selection box, it is premultiplied by its alpha.
+	BandColor = color.RGBA{R: 0x00, G: 0x29, B: 0x66, A: 0x66}
+)
+
+const (
+	// Outlines are drawn this far from the bounds of shapes, in pixels.
+	outlineMargin = 3
+
+	// Arrow keys move the selection by nudgeStep pixels, or by
+	// nudgeShiftStep with Shift held.
+	nudgeStep      = 1
+	nudgeShiftStep = 10
+)
+
+// ClickHandler turns the mouse and the keyboard into operations posted to
+// the loop, it looks at the scene through copies of the shapes, so the ids
+// tell the same shapes apart from one copy to another.
 type ClickHandler struct {
-	pressed bool
-	tf      *TFigure
-	start   image.Point
+	// selection holds the ids of the selected shapes together with their
+	// groups.
+	selection []int
+
+	// dragging is set while the left button holds the selection, it
+	// follows the cursor from the point pressed by the anchor of the shape
+	// grabbed, dragged is the offset already posted.
+	dragging bool
+	from     Point
+	anchor   Point
+	dragged  Point
+
+	// box is the rectangle around the selection when the drag started,
+	// guides show where it lines up with the other shapes.
+	box    Rectangle
+	guides []guide
+
+	// grip is the handle of the selection held by the left button.
+	grip gripState
+
+	// gesture is the ID of the latest drag, pull of a handle, nudge or
+	// paste, the loop undoes their operations together.
+	gesture int
+
+	// banding is set while the left button stretches the selection box over
+	// an empty place.
+	banding   bool
+	bandStart image.Point
+	bandEnd   image.Point
+
+	hovered int
+
+	panning  bool
+	panStart image.Point
 
-	GetTFigures func() []*TFigure
+	// GetShapes returns the visible shapes in the order they are drawn.
+	GetShapes   func() []Shape
+	GetViewport func() Viewport
+
+	// GetGroup returns the shapes selected and dragged together with the
+	// shape.
+	GetGroup func(sh Shape) []Shape
+
+	// GetGrid returns the grid drags snap to, there is none when it is nil.
+	GetGrid func() Grid
+
+	PostOperation func(op Operation)
+}
+
+func (cl *ClickHandler) GetShapeUnderPoint(sp image.Point) (Shape, bool) {
+	shapes := cl.GetShapes()
+	p := cl.GetViewport().ToCanvas(sp)
+
+	for i := len(shapes) - 1; i >= 0; i-- {
+		sh := shapes[i]
+
+		if sh.Contains(p) {
+			return sh, true
+		}
+	}
+
+	return nil, false
 }
 
-func (cl *ClickHandler) GetTFigureUnderPoint(p image.Point) (*TFigure, bool) {
-	tfs := cl.GetTFigures()
+// Selection returns the selected shapes in the order they were selected.
+func (cl *ClickHandler) Selection() (selection []Shape) {
+	shapes := cl.GetShapes()
 
-	for i := len(tfs) - 1; i >= 0; i-- {
-		tf := tfs[i]
+	for _, id := range cl.selection {
+		if sh, ok := shapeWithID(shapes, id); ok {
+			selection = append(selection, sh)
+		}
+	}
+
+	return
+}
+
+// IsSelected tells whether the shape is selected.
+func (cl *ClickHandler) IsSelected(sh Shape) bool {
+	return containsID(cl.selection, sh.GetID())
+}
 
-		if tf.Contains(p) {
-			return tf, true
+func shapeWithID(shapes []Shape, id int) (Shape, bool) {
+	for _, sh := range shapes {
+		if sh.GetID() == id {
+			return sh, true
 		}
 	}
 
 	return nil, false
 }
 
-func (cl *ClickHandler) grabTFigure(sp image.Point) {
-	tf, ok := cl.GetTFigureUnderPoint(sp)
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cl *ClickHandler) groupOf(sh Shape) []Shape {
+	if cl.GetGroup == nil {
+		return []Shape{sh}
+	}
+
+	return cl.GetGroup(sh)
+}
 
-	if !ok {
-		cl.tf = nil
+// selectShape adds the shape with its group to the selection.
+func (cl *ClickHandler) selectShape(sh Shape) {
+	for _, s := range cl.groupOf(sh) {
+		if !cl.IsSelected(s) {
+			cl.selection = append(cl.selection, s.GetID())
+		}
+	}
+}
+
+// deselectShape removes the shape with its group from the selection.
+func (cl *ClickHandler) deselectShape(sh Shape) {
+	for _, s := range cl.groupOf(sh) {
+		cl.selection = removeID(cl.selection, s.GetID())
+	}
+}
+
+func (cl *ClickHandler) clearSelection() {
+	cl.selection = nil
+	cl.dragging = false
+}
+
+// press selects the shape under the cursor and starts dragging the
+// selection, or starts the selection box on an empty place. With Shift the
+// shape is added to the selection or taken out of it.
+func (cl *ClickHandler) press(sp image.Point, shift bool) {
+	if !shift && cl.grab(sp) {
 		return
 	}
 
-	cl.tf = tf
-	cl.start = sp
+	sh, ok := cl.GetShapeUnderPoint(sp)
+
+	switch {
+	case !ok:
+		if !shift {
+			cl.clearSelection()
+		}
+		cl.banding = true
+		cl.bandStart, cl.bandEnd = sp, sp
+		return
+
+	case shift && cl.IsSelected(sh):
+		cl.deselectShape(sh)
+		return
+
+	case !shift && !cl.IsSelected(sh):
+		cl.selection = nil
+	}
+
+	cl.selectShape(sh)
+	cl.startGesture()
+	cl.dragging = true
+	cl.from = cl.GetViewport().ToCanvas(sp)
+	cl.anchor = shapeAnchor(sh)
+	cl.dragged = Point{}
+	cl.box, _ = cl.selectionBounds()
 }
 
-func (cl *ClickHandler) releaseTFigure() {
-	cl.tf = nil
-	cl.start = image.Point{}
+// shapeAnchor returns the point of the shape put on the grid: the center
+// of a figure and the top-left corner of anything else.
+func shapeAnchor(sh Shape) Point {
+	if tf, ok := sh.(*TFigure); ok {
+		return tf.Center
+	}
+
+	return sh.Bounds().Min
 }
 
-func (cl *ClickHandler) Update(e mouse.Event) bool {
-	if e.Button == mouse.ButtonRight {
-		dest := image.Point{int(e.X), int(e.Y)}
-		cl.pressed = !cl.pressed
-		if cl.pressed {
-			cl.grabTFigure(dest)
-		} else {
-			cl.releaseTFigure()
+// band returns the selection box in canvas coordinates.
+func (cl *ClickHandler) band(vp Viewport) Rectangle {
+	a, b := vp.ToCanvas(cl.bandStart), vp.ToCanvas(cl.bandEnd)
+
+	return Rectangle{Min: a, Max: a}.Union(Rectangle{Min: b, Max: b})
+}
+
+// selectBand adds the shapes lying inside of the selection box to the
+// selection.
+func (cl *ClickHandler) selectBand() {
+	band := cl.band(cl.GetViewport())
+
+	for _, sh := range cl.GetShapes() {
+		if sh.Bounds().In(band) {
+			cl.selectShape(sh)
 		}
 	}
 
-	if cl.pressed {
-		dest := image.Point{int(e.X), int(e.Y)}
+	cl.banding = false
+}
+
+// hover remembers the shape under the cursor and tells whether it changed.
+func (cl *ClickHandler) hover(sp image.Point) bool {
+	id := 0
+	if sh, ok := cl.GetShapeUnderPoint(sp); ok {
+		id = sh.GetID()
+	}
+
+	if id == cl.hovered {
+		return false
+	}
+
+	cl.hovered = id
+
+	return true
+}
+
+// updateCamera zooms the view with the scroll wheel and pans it while the
+// middle button is held.
+func (cl *ClickHandler) updateCamera(e mouse.Event) {
+	dest := image.Point{int(e.X), int(e.Y)}
+
+	switch {
+	case e.Button == mouse.ButtonWheelUp || e.Button == mouse.ButtonWheelDown:
+		factor := ZoomStep
+		if e.Button == mouse.ButtonWheelDown {
+			factor = 1 / ZoomStep
+		}
+
+		at := cl.GetViewport().ToCanvas(dest)
+		cl.PostOperation(NewZoom(factor, &at))
+
+	case e.Button == mouse.ButtonMiddle && e.Direction == mouse.DirPress:
+		cl.panning = true
+		cl.panStart = dest
+
+	case e.Button == mouse.ButtonMiddle && e.Direction == mouse.DirRelease:
+		cl.panning = false
+
+	case cl.panning && e.Direction == mouse.DirNone:
+		offset := cl.GetViewport().ToViewVector(dest.Sub(cl.panStart))
+		cl.PostOperation(NewPan(offset.X, offset.Y))
+		cl.panStart = dest
+	}
+}
+
+// Update handles the mouse: the left button selects the shape under the
+// cursor and drags the selection while held, Shift adds shapes to the
+// selection or takes them out. Pressing on an empty place clears the
+// selection and stretches a box, the shapes inside of it are selected on
+// release. The handles around the selection resize and turn it. It tells
+// whether the window has to be redrawn.
+func (cl *ClickHandler) Update(e mouse.Event) bool {
+	cl.updateCamera(e)
+
+	dest := image.Point{int(e.X), int(e.Y)}
+	shift := e.Modifiers&key.ModShift != 0
+
+	switch {
+	case e.Button == mouse.ButtonLeft && e.Direction == mouse.DirPress:
+		cl.press(dest, shift)
+		return true
+
+	case e.Button == mouse.ButtonLeft && e.Direction == mouse.DirRelease:
+		if cl.grip.kind != noGrip {
+			cl.pull(dest, shift)
+			cl.grip = gripState{}
+		}
+		if cl.dragging {
+			cl.handle(dest)
+			cl.dragging = false
+			cl.guides = nil
+		}
+		if cl.banding {
+			cl.bandEnd = dest
+			cl.selectBand()
+		}
+		return true
+
+	case e.Direction == mouse.DirNone && cl.grip.kind != noGrip:
+		cl.pull(dest, shift)
+		return true
+
+	case e.Direction == mouse.DirNone && cl.dragging:
 		cl.handle(dest)
+		return true
 
+	case e.Direction == mouse.DirNone && cl.banding:
+		cl.bandEnd = dest
+		cl.hover(dest)
 		return true
+
+	case e.Direction == mouse.DirNone:
+		return cl.hover(dest)
 	}
 
 	return false
 }
 
-func (cl *ClickHandler) grabbedTFigureIsPresent() bool {
-	tfs := cl.GetTFigures()
+// Nudge moves the selection by the offset in pixels and tells whether there
+// was anything to move.
+func (cl *ClickHandler) Nudge(offset image.Point) bool {
+	cl.dropMissing()
 
-	for _, tf := range tfs {
-		if tf == cl.tf {
-			return true
+	if len(cl.selection) == 0 {
+		return false
+	}
+
+	cl.startGesture()
+	cl.moveSelection(cl.canvasOffset(offset))
+
+	return true
+}
+
+// Duplicate adds copies of the selected shapes shifted by the offset in
+// pixels and tells whether there was anything to copy.
+func (cl *ClickHandler) Duplicate(offset image.Point) bool {
+	return cl.Paste(cl.Copy(), offset)
+}
+
+// Copy returns the ids of the selected shapes. The black rectangle is left
+// out, since there is only one.
+func (cl *ClickHandler) Copy() (ids []int) {
+	for _, sh := range cl.Selection() {
+		if _, ok := sh.(*BRect); !ok {
+			ids = append(ids, sh.GetID())
 		}
 	}
 
-	return false
+	return
+}
+
+// Paste adds copies of the shapes with the ids shifted by the offset in
+// pixels and tells whether there were any.
+func (cl *ClickHandler) Paste(ids []int, offset image.Point) bool {
+	v := cl.canvasOffset(offset)
+	cl.startGesture()
+
+	for _, id := range ids {
+		cl.postGesture(NewClone(id, v.X, v.Y))
+	}
+
+	return len(ids) != 0
+}
+
+// startGesture makes the operations posted next a gesture of their own.
+func (cl *ClickHandler) startGesture() {
+	cl.gesture++
+}
+
+// postGesture posts the operation as a part of the current gesture.
+func (cl *ClickHandler) postGesture(op Operation) {
+	cl.PostOperation(Gesture{Operation: op, ID: cl.gesture})
+}
+
+// canvasOffset converts the offset in pixels to the canvas.
+func (cl *ClickHandler) canvasOffset(offset image.Point) Point {
+	vp := cl.GetViewport()
+	start, end := vp.ToCanvas(image.Point{}), vp.ToCanvas(offset)
+
+	return Point{X: end.X - start.X, Y: end.Y - start.Y}
+}
+
+// dropMissing takes the shapes removed from the scene out of the selection.
+func (cl *ClickHandler) dropMissing() {
+	present := cl.selection[:0]
+	shapes := cl.GetShapes()
+
+	for _, id := range cl.selection {
+		if _, ok := shapeWithID(shapes, id); ok {
+			present = append(present, id)
+		}
+	}
+
+	cl.selection = present
+
+	if len(present) == 0 {
+		cl.dragging = false
+	}
+
+	if _, ok := shapeWithID(shapes, cl.hovered); !ok {
+		cl.hovered = 0
+	}
+}
+
+// representatives returns an id of a shape of every selected group, so an
+// operation posted for each of them applies to the selection once.
+func (cl *ClickHandler) representatives() (ids []int) {
+	var covered []int
+
+	for _, sh := range cl.Selection() {
+		if containsID(covered, sh.GetID()) {
+			continue
+		}
+
+		for _, s := range cl.groupOf(sh) {
+			covered = append(covered, s.GetID())
+		}
+
+		ids = append(ids, sh.GetID())
+	}
+
+	return
+}
+
+func (cl *ClickHandler) moveSelection(offset Point) {
+	for _, id := range cl.representatives() {
+		cl.postGesture(NewMoveShape(id, offset.X, offset.Y))
+	}
 }
 
 func (cl *ClickHandler) handle(dest image.Point) {
-	if cl.tf == nil {
+	cl.dropMissing()
+
+	if !cl.dragging {
 		return
 	}
 
-	if !cl.grabbedTFigureIsPresent() {
-		cl.releaseTFigure()
+	vp := cl.GetViewport()
+	p := vp.ToCanvas(dest)
+	target := Point{X: cl.anchor.X + p.X - cl.from.X, Y: cl.anchor.Y + p.Y - cl.from.Y}
+	reach := guideReach * vp.PixelSize()
+
+	if cl.GetGrid != nil && cl.GetGrid().Snap {
+		target = cl.GetGrid().SnapPoint(target)
+		reach = 0
+	}
+
+	box := cl.box.Add(Point{X: target.X - cl.anchor.X, Y: target.Y - cl.anchor.Y})
+	pull, guides := alignGuides(box, cl.others(), reach)
+
+	target.X += pull.X
+	target.Y += pull.Y
+	cl.guides = guides
+
+	offset := Point{X: target.X - cl.anchor.X - cl.dragged.X, Y: target.Y - cl.anchor.Y - cl.dragged.Y}
+
+	if offset == (Point{}) {
 		return
 	}
 
-	cl.tf.Move(image.Point{
-		dest.X - cl.start.X,
-		dest.Y - cl.start.Y,
-	})
+	cl.moveSelection(offset)
+
+	cl.dragged.X += offset.X
+	cl.dragged.Y += offset.Y
+}
+
+// others returns the bounds of the shapes out of the selection.
+func (cl *ClickHandler) others() (bounds []Rectangle) {
+	for _, sh := range cl.GetShapes() {
+		if !cl.IsSelected(sh) {
+			bounds = append(bounds, sh.Bounds())
+		}
+	}
+
+	return
+}
+
+// Draw outlines the shape under the cursor, highlights the selected ones
+// with the handles around them, shows the guides of the drag and the
+// selection box above the scene.
+func (cl *ClickHandler) Draw(c Canvas, vp Viewport) {
+	cl.dropMissing()
+
+	px := vp.PixelSize()
+	shapes := cl.GetShapes()
+
+	if hovered, ok := shapeWithID(shapes, cl.hovered); ok && !cl.IsSelected(hovered) {
+		drawOutline(c, vp, cl.groupOf(hovered), Stroke{Color: HoverColor, Width: px})
+	}
+
+	for _, sh := range cl.Selection() {
+		drawOutline(c, vp, []Shape{sh}, Stroke{Color: SelectionColor, Width: 2 * px, Dash: []float64{6 * px, 3 * px}})
+	}
+
+	if bounds, ok := cl.selectionBounds(); ok && !cl.dragging {
+		drawGrips(c, vp, bounds)
+	}
+
+	if cl.dragging {
+		for _, g := range cl.guides {
+			g.Draw(c, vp)
+		}
+	}
+
+	if cl.banding {
+		band := cl.band(vp)
+
+		fillPolygons(c, vp, [][]Point{band.Polygon()}, BandColor, draw.Over)
+		Stroke{Color: SelectionColor, Width: px}.Draw(c, vp, band.Polygon(), true)
+	}
+}
+
+// drawOutline strokes the rectangle around the shapes a little away from
+// their bounds.
+func drawOutline(c Canvas, vp Viewport, shapes []Shape, s Stroke) {
+	if len(shapes) == 0 {
+		return
+	}
+
+	bounds := boundsOf(shapes).Grow(outlineMargin * vp.PixelSize())
+
+	s.Draw(c, vp, bounds.Polygon(), true)
+}
+
+// boundsOf returns the smallest rectangle containing the shapes, there has
+// to be at least one of them.
+func boundsOf(shapes []Shape) Rectangle {
+	bounds := shapes[0].Bounds()
+	for _, sh := range shapes[1:] {
+		bounds = bounds.Union(sh.Bounds())
+	}
 
-	cl.start.X = dest.X
-	cl.start.Y = dest.Y
+	return bounds
 }
@@ -0,0 +1,277 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func newTestClickHandler(gen *Generator) *ClickHandler {
+	size := image.Pt(100, 100)
+
+	return &ClickHandler{
+		GetShapes:     gen.Snapshot,
+		GetViewport:   func() Viewport { return gen.Viewport(size) },
+		GetGroup:      gen.GroupShapes,
+		GetGrid:       gen.Grid,
+		PostOperation: gen.Update,
+	}
+}
+
+func TestClickHandler_Drag(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewTFigure(0.2, 0.2))
+
+	cl := newTestClickHandler(&gen)
+	tf := gen.GetTFigures()[0]
+
+	if !cl.Update(mouse.Event{X: 50, Y: 45}) || cl.Update(mouse.Event{X: 51, Y: 45}) {
+		t.Errorf("only entering the figure has to redraw the window")
+	}
+
+	if cl.hovered != tf.ID {
+		t.Errorf("hovered shape is %d, expected the figure", cl.hovered)
+	}
+
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 60, Y: 45})
+	cl.Update(mouse.Event{X: 70, Y: 55, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	if !near(tf.Center, Point{0.7, 0.6}) {
+		t.Errorf("figure is at %v after the drag, expected %v", tf.Center, Point{0.7, 0.6})
+	}
+
+	// the selection stays after the button is released, but moving the
+	// cursor does not drag the figure anymore
+	cl.Update(mouse.Event{X: 90, Y: 90})
+
+	if sel := cl.Selection(); len(sel) != 1 || sel[0].GetID() != tf.ID || !near(tf.Center, Point{0.7, 0.6}) {
+		t.Errorf("figure has to stay selected and in place after release")
+	}
+
+	img := NewImageCanvas(image.Pt(100, 100))
+	cl.Draw(img, gen.Viewport(image.Pt(100, 100)))
+
+	if got := img.RGBAAt(65, 44); got != SelectionColor {
+		t.Errorf("selection highlight is %v, expected %v", got, SelectionColor)
+	}
+
+	if got := img.RGBAAt(54, 44); got != HandleColor {
+		t.Errorf("corner handle is %v, expected %v", got, HandleColor)
+	}
+
+	cl.Update(mouse.Event{X: 95, Y: 5, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+
+	if len(cl.Selection()) != 0 {
+		t.Errorf("click on an empty place has to clear the selection")
+	}
+
+	cl.Update(mouse.Event{X: 95, Y: 5, Button: mouse.ButtonRight, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 20, Y: 20})
+
+	if !near(tf.Center, Point{0.7, 0.6}) {
+		t.Errorf("right button must not drag the figure anymore")
+	}
+}
+
+func TestClickHandler_Gestures(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.5, 0.5))
+
+	var posted []Gesture
+
+	cl := newTestClickHandler(&gen)
+	cl.PostOperation = func(op Operation) {
+		posted = append(posted, op.(Gesture))
+		gen.Update(op)
+	}
+
+	drag := func(x, y int) {
+		cl.Update(mouse.Event{X: float32(x), Y: float32(y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+		cl.Update(mouse.Event{X: float32(x + 5), Y: float32(y)})
+		cl.Update(mouse.Event{X: float32(x + 10), Y: float32(y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	}
+
+	drag(50, 45)
+	drag(60, 45)
+
+	if len(posted) != 4 || posted[0].ID != posted[1].ID || posted[1].ID == posted[2].ID || posted[2].ID != posted[3].ID {
+		t.Errorf("every drag has to be a gesture of its own, got %v", posted)
+	}
+
+	cl.Nudge(image.Pt(1, 0))
+
+	if last := posted[len(posted)-1]; last.ID == posted[3].ID {
+		t.Errorf("a nudge has to be a gesture of its own")
+	}
+}
+
+func TestClickHandler_MultiSelection(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.2, 0.2))
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewTFigure(0.8, 0.8))
+
+	cl := newTestClickHandler(&gen)
+	tfs := gen.GetTFigures()
+	shift := key.ModShift
+
+	cl.Update(mouse.Event{X: 20, Y: 15, Button: mouse.ButtonLeft, Direction: mouse.DirPress, Modifiers: shift})
+	cl.Update(mouse.Event{X: 20, Y: 15, Button: mouse.ButtonLeft, Direction: mouse.DirRelease, Modifiers: shift})
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress, Modifiers: shift})
+	cl.Update(mouse.Event{X: 55, Y: 50})
+	cl.Update(mouse.Event{X: 55, Y: 50, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	if sel := cl.Selection(); len(sel) != 2 {
+		t.Fatalf("%d shapes are selected, expected 2", len(sel))
+	}
+
+	sc := Shortcuts{Bindings: DefaultBindings(), Selection: cl, PostOperation: gen.Update}
+	sc.HandleKey(key.Event{Code: key.CodeRightArrow, Direction: key.DirPress})
+	sc.HandleKey(key.Event{Code: key.CodeDownArrow, Direction: key.DirPress, Modifiers: shift})
+
+	expected := []Point{{0.26, 0.35}, {0.56, 0.65}, {0.8, 0.8}}
+
+	for i, tf := range tfs {
+		if !near(tf.Center, expected[i]) {
+			t.Errorf("figure %d is at %v, expected %v", i+1, tf.Center, expected[i])
+		}
+	}
+
+	cl.Update(mouse.Event{X: 56, Y: 60, Button: mouse.ButtonLeft, Direction: mouse.DirPress, Modifiers: shift})
+
+	if sel := cl.Selection(); len(sel) != 1 || sel[0].GetID() != tfs[0].ID {
+		t.Errorf("shift click has to take the figure out of the selection")
+	}
+
+	// the box selects only the shapes lying inside of it
+	cl.Update(mouse.Event{X: 99, Y: 60, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 62, Y: 99})
+	cl.Update(mouse.Event{X: 62, Y: 99, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	if sel := cl.Selection(); len(sel) != 1 || sel[0].GetID() != tfs[2].ID {
+		t.Errorf("selection box has to select only the last figure, got %v", sel)
+	}
+}
+
+func TestClickHandler_Handles(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.5, 0.5))
+
+	cl := newTestClickHandler(&gen)
+	vp := cl.GetViewport()
+	tf := gen.GetTFigures()[0]
+
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	// the bottom right corner is pulled while the top left one stays
+	cl.Update(mouse.Event{X: 65, Y: 65, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 80, Y: 80})
+	cl.Update(mouse.Event{X: 92, Y: 92, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	s := (0.92 - 0.375) / (0.65 - 0.375)
+	expected := Rectangle{Min: Point{0.375, 0.375}, Max: Point{0.375 + 0.25*s, 0.375 + 0.25*s}}
+
+	if bounds := tf.Bounds(); !near(bounds.Min, expected.Min) || !near(bounds.Max, expected.Max) {
+		t.Errorf("figure bounds are %v after resize, expected %v", bounds, expected)
+	}
+
+	_, rotation := grips(tf.Bounds(), vp)
+	bounds := tf.Bounds()
+	center := Point{X: (bounds.Min.X + bounds.Max.X) / 2, Y: (bounds.Min.Y + bounds.Max.Y) / 2}
+	from, to := vp.ToImage(rotation), vp.ToImage(Point{X: center.X + 0.3, Y: center.Y + 0.01})
+
+	cl.Update(mouse.Event{X: float32(from.X), Y: float32(from.Y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: float32(to.X), Y: float32(to.Y), Modifiers: key.ModShift})
+	cl.Update(mouse.Event{X: float32(to.X), Y: float32(to.Y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease, Modifiers: key.ModShift})
+
+	if m := tf.Transform; math.Abs(m.A) > 1e-9 || math.Abs(m.B-s) > 1e-9 {
+		t.Errorf("figure transform is %v, expected a quarter turn of the scaled figure", m)
+	}
+}
+
+func TestClickHandler_Snap(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(ToggleSnap{})
+
+	cl := newTestClickHandler(&gen)
+	tf := gen.GetTFigures()[0]
+
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 52, Y: 46})
+
+	if !near(tf.Center, Point{0.5, 0.5}) {
+		t.Errorf("figure has to stay on the grid crossing, it is at %v", tf.Center)
+	}
+
+	cl.Update(mouse.Event{X: 57, Y: 49, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	if !near(tf.Center, Point{0.55, 0.55}) {
+		t.Errorf("figure is dragged to %v, expected %v", tf.Center, Point{0.55, 0.55})
+	}
+}
+
+func TestClickHandler_Guides(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.3, 0.3))
+	gen.Update(NewTFigure(0.6, 0.5))
+
+	cl := newTestClickHandler(&gen)
+	tfs := gen.GetTFigures()
+
+	cl.Update(mouse.Event{X: 60, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 60, Y: 27})
+
+	// two pixels away from the other figure it is pulled in line with it
+	if !near(tfs[1].Center, Point{0.6, 0.3}) {
+		t.Errorf("figure is dragged to %v, expected %v", tfs[1].Center, Point{0.6, 0.3})
+	}
+
+	if len(cl.guides) == 0 {
+		t.Fatalf("guides have to be shown while the figures line up")
+	}
+
+	img := NewImageCanvas(image.Pt(100, 100))
+	cl.Draw(img, gen.Viewport(image.Pt(100, 100)))
+
+	if got := img.RGBAAt(45, 30); got == (color.RGBA{}) {
+		t.Errorf("guide between the figures is not drawn, got %v", got)
+	}
+
+	cl.Update(mouse.Event{X: 60, Y: 75, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	if len(cl.guides) != 0 || !near(tfs[1].Center, Point{0.6, 0.8}) {
+		t.Errorf("figure is dropped at %v with %d guides", tfs[1].Center, len(cl.guides))
+	}
+}
+
+func TestClickHandler_Letterbox(t *testing.T) {
+	gen := Generator{Aspect: 1, Scale: ScaleFit}
+	gen.Update(NewTFigure(0.5, 0.5))
+
+	cl := newTestClickHandler(&gen)
+	cl.GetViewport = func() Viewport { return gen.Viewport(image.Pt(200, 100)) }
+	tf := gen.GetTFigures()[0]
+
+	// the canvas takes the middle of the wide window
+	cl.Update(mouse.Event{X: 100, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 110, Y: 55, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	if !near(tf.Center, Point{0.6, 0.6}) {
+		t.Errorf("figure is dragged to %v, expected %v", tf.Center, Point{0.6, 0.6})
+	}
+
+	cl.Update(mouse.Event{X: 20, Y: 50, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 20, Y: 50, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	if len(cl.Selection()) != 0 {
+		t.Errorf("click on the bar has to clear the selection")
+	}
+}
@@ -0,0 +1,55 @@
+package painter
+
+import (
+	"log"
+	"strconv"
+)
+
+// cloneShape adds a copy of the shape with the id moved by the offset on top
+// of the layer of the shape, the copy gets a new id. The black rectangle is
+// not copied since there is only one. The store has to be locked by the
+// caller.
+func (gn *Generator) cloneShape(id int, offset Point) {
+	l, i, ok := gn.locateShape(id)
+
+	if !ok {
+		log.Printf("no shape with id %d to clone", id)
+		return
+	}
+
+	if _, ok := l.shapes[i].(*BRect); ok {
+		log.Printf("black rectangle %d can not be cloned", id)
+		return
+	}
+
+	sh := l.shapes[i].clone()
+	sh.Move(offset)
+
+	gn.store.lastID++
+	sh.setID(gn.store.lastID)
+	l.shapes = append(l.shapes, sh)
+}
+
+// Clone adds a copy of the shape with the ID moved by the Offset.
+type Clone struct {
+	ID     int
+	Offset Point
+}
+
+func (c Clone) String() string {
+	s := "clone " + strconv.Itoa(c.ID)
+
+	if c.Offset != (Point{}) {
+		s += " " + formatFloat(c.Offset.X) + " " + formatFloat(c.Offset.Y)
+	}
+
+	return s
+}
+
+func (c Clone) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func NewClone(id int, dx, dy float64) Clone {
+	return Clone{ID: id, Offset: Point{X: dx, Y: dy}}
+}
@@ -3,6 +3,7 @@ package painter
 import "image"
 import "golang.org/x/exp/shiny/screen"
 import "sync"
+import "log"
 
 type TextureGenerator interface {
 	SetScreen(scr screen.Screen)
@@ -11,37 +12,78 @@ type TextureGenerator interface {
 }
 
 type Store struct {
-	tfigures    []*TFigure
-	backgrounds []*Fill
-	brect       *BRect
-	moves       []*Move
+	// layers are sorted by z, shapes are added to the current one.
+	layers  []*layer
+	current *layer
 
-	tfiguresM    sync.Mutex
+	// brect is the single black rectangle, a new one replaces it.
+	brect *BRect
+
+	// groups are found by their names, shapeGroups by the ids of shapes
+	// directly inside of them.
+	groups      map[string]*group
+	shapeGroups map[int]*group
+
+	backgrounds []DrawableElement
+	camera      Camera
+
+	// grid is drawn above the backgrounds and puts the new shapes in place.
+	grid Grid
+
+	// lastID is the id of the latest shape, they are numbered from one.
+	lastID int
+
+	shapesM      sync.Mutex
 	backgroundsM sync.Mutex
-	brectM       sync.Mutex
-	movesM       sync.Mutex
+	cameraM      sync.Mutex
 }
 
 func (store *Store) Lock() {
-	store.tfiguresM.Lock()
+	store.shapesM.Lock()
 	store.backgroundsM.Lock()
-	store.brectM.Lock()
-	store.movesM.Lock()
+	store.cameraM.Lock()
 }
 
 func (store *Store) Unlock() {
-	store.movesM.Unlock()
-	store.brectM.Unlock()
+	store.cameraM.Unlock()
 	store.backgroundsM.Unlock()
-	store.tfiguresM.Unlock()
+	store.shapesM.Unlock()
+}
+
+// shapes returns every shape in the order they are drawn, the store has to
+// be locked by the caller.
+func (store *Store) shapes() (shapes []Shape) {
+	for _, l := range store.layers {
+		shapes = append(shapes, l.shapes...)
+	}
+
+	return
 }
 
 type Generator struct {
-	store Store
-	Scr   screen.Screen
+	store  Store
+	assets assetStore
+	Scr    screen.Screen
+
+	// Antialias renders frames with smooth edges into a buffer, which is
+	// uploaded into the texture afterwards.
+	Antialias bool
+
+	// Overlays are drawn above the scene and show the state of the UI.
+	Overlays []DrawableElement
+
+	// Aspect is the width of the canvas divided by its height, windows of
+	// another shape show it as the Scale tells. The canvas takes the shape
+	// of the window when the Aspect is not given.
+	Aspect float64
+	Scale  ScaleMode
 }
 
 func (gn *Generator) Update(op Operation) {
+	if g, ok := op.(Gesture); ok {
+		op = g.Operation
+	}
+
 	defer gn.store.Unlock()
 
 	gn.store.Lock()
@@ -49,48 +91,337 @@ func (gn *Generator) Update(op Operation) {
 	switch op := op.(type) {
 	case Fill:
 		gn.store.backgrounds = append(gn.store.backgrounds, &op)
+	case GradientFill:
+		if op.ID == 0 {
+			gn.store.backgrounds = append(gn.store.backgrounds, &op)
+		} else if sh, ok := gn.findShape(op.ID); ok {
+			if gf, ok := sh.(gradientFilled); ok {
+				gf.setGradient(&op.Gradient)
+			} else {
+				log.Printf("shape with id %d can not be filled with a gradient", op.ID)
+			}
+		}
+	case SetStroke:
+		if sh, ok := gn.findShape(op.ID); ok {
+			if o, ok := sh.(outlined); ok {
+				o.setStroke(op.Stroke)
+			} else {
+				log.Printf("shape with id %d can not be outlined", op.ID)
+			}
+		}
 	case TFigure:
-		gn.store.tfigures = append(gn.store.tfigures, &op)
+		gn.snapShape(&op)
+		gn.addShape(&op)
+	case Line:
+		gn.addShape(&op)
+	case Text:
+		gn.addShape(&op)
+	case Picture:
+		op.assets = &gn.assets
+		gn.addShape(&op)
 	case BRect:
-		gn.store.brect = &op
+		gn.snapShape(&op)
+		gn.replaceBRect(&op)
 	case Move:
-		op.SetRange(gn.store.tfigures)
-		gn.store.moves = append(gn.store.moves, &op)
+		if op.ID != 0 {
+			op.SetRange(gn.targets(op.ID))
+		} else {
+			op.SetRange(gn.movedShapes())
+		}
+		op.Move()
+	case MoveTo:
+		op.Dest = gn.store.grid.SnapPoint(op.Dest)
+		if op.ID != 0 {
+			op.SetRange(gn.targets(op.ID))
+		} else {
+			op.SetRange(gn.movedShapes())
+		}
+		op.Move()
+	case Layer:
+		l := gn.layerNamed(op.Name)
+		if op.Z != nil {
+			l.z = *op.Z
+			gn.sortLayers()
+		}
+		gn.store.current = l
+	case ShowLayer:
+		if l, ok := gn.findLayer(op.Name); ok {
+			l.hidden = false
+		}
+	case HideLayer:
+		if l, ok := gn.findLayer(op.Name); ok {
+			l.hidden = true
+		}
+	case LayerOpacity:
+		if l, ok := gn.findLayer(op.Name); ok {
+			l.opacity = op.Opacity
+		}
+	case BringToFront:
+		gn.restack(op.ID, true)
+	case SendToBack:
+		gn.restack(op.ID, false)
+	case Zoom:
+		at := gn.store.camera.Center()
+		if op.At != nil {
+			at = *op.At
+		}
+		gn.store.camera.ZoomAt(op.Factor, at)
+	case Pan:
+		gn.store.camera.Pan(op.Offset)
+	case Fit:
+		gn.store.camera.Fit(gn.contentBounds())
+	case Rotate:
+		gn.transformTargets(op.ID, op.Around, func(p Point) Affine {
+			return Rotation(op.Degrees, p)
+		})
+	case Scale:
+		gn.transformTargets(op.ID, op.Around, func(p Point) Affine {
+			return Scaling(op.Factor, p)
+		})
+	case Transform:
+		gn.transformTargets(op.ID, nil, func(Point) Affine {
+			return op.Matrix
+		})
+	case Group:
+		gn.addGroup(op)
+	case Ungroup:
+		gn.ungroup(op.Name)
+	case Delete:
+		gn.deleteShapes(op.ID)
+	case SetGrid:
+		if op.Spacing == 0 {
+			gn.store.grid.Visible = !gn.store.grid.Visible
+		} else {
+			gn.store.grid.Spacing = op.Spacing
+			gn.store.grid.Visible = true
+		}
+	case ToggleSnap:
+		gn.store.grid.Snap = !gn.store.grid.Snap
+	case Clone:
+		gn.cloneShape(op.ID, op.Offset)
+	case Align:
+		gn.align(op)
+	case Distribute:
+		gn.distribute(op)
+	case Resize:
+		if tf, ok := gn.findTFigure(op.ID); ok {
+			tf.Resize(op.Size)
+		}
 	case Reset:
 		gn.store.backgrounds = gn.store.backgrounds[:0]
-		gn.store.tfigures = gn.store.tfigures[:0]
-		gn.store.moves = gn.store.moves[:0]
+		gn.store.layers = nil
+		gn.store.current = nil
 		gn.store.brect = nil
+		gn.store.groups = nil
+		gn.store.shapeGroups = nil
+		gn.store.camera = Camera{}
+		gn.store.grid = Grid{}
+		gn.store.lastID = 0
 	}
 }
 
-type DrawableElement interface {
-	Draw(t screen.Texture)
+// addShape numbers the shape and puts it on top of the others of the
+// current layer, the store has to be locked by the caller.
+func (gn *Generator) addShape(sh Shape) {
+	gn.store.lastID++
+	sh.setID(gn.store.lastID)
+
+	l := gn.currentLayer()
+	l.shapes = append(l.shapes, sh)
 }
 
-func (gn *Generator) getGenerationData() (elements []DrawableElement, moves []*Move) {
-	defer gn.store.Unlock()
+// replaceBRect removes the black rectangle and puts the new one below the
+// shapes of the current layer, the store has to be locked by the caller.
+func (gn *Generator) replaceBRect(brect *BRect) {
+	if gn.store.brect != nil {
+		if l, i, ok := gn.locateShape(gn.store.brect.ID); ok {
+			l.shapes = append(l.shapes[:i], l.shapes[i+1:]...)
+		}
 
-	gn.store.Lock()
+		if g := gn.store.shapeGroups[gn.store.brect.ID]; g != nil {
+			g.shapes = removeID(g.shapes, gn.store.brect.ID)
+			delete(gn.store.shapeGroups, gn.store.brect.ID)
+		}
+	}
 
+	gn.addShape(brect)
+	gn.restack(brect.ID, false)
+	gn.store.brect = brect
+}
+
+// movedShapes returns the shapes moved by the move commands, which leave
+// the black rectangle in place. The store has to be locked by the caller.
+func (gn *Generator) movedShapes() (shapes []Shape) {
+	for _, sh := range gn.store.shapes() {
+		if _, ok := sh.(*BRect); !ok {
+			shapes = append(shapes, sh)
+		}
+	}
+
+	return
+}
+
+// findShape looks for the shape with the id in the store, which has to be
+// locked by the caller.
+func (gn *Generator) findShape(id int) (Shape, bool) {
+	l, i, ok := gn.locateShape(id)
+
+	if !ok {
+		log.Printf("no shape with id %d", id)
+		return nil, false
+	}
+
+	return l.shapes[i], true
+}
+
+// findTFigure looks for the figure with the id in the store, which has to be
+// locked by the caller.
+func (gn *Generator) findTFigure(id int) (*TFigure, bool) {
+	sh, ok := gn.findShape(id)
+
+	if !ok {
+		return nil, false
+	}
+
+	tf, ok := sh.(*TFigure)
+
+	if !ok {
+		log.Printf("shape with id %d is not a figure", id)
+	}
+
+	return tf, ok
+}
+
+// contentBounds returns the smallest rectangle containing every shape of
+// the store, which has to be locked by the caller.
+func (gn *Generator) contentBounds() (bounds Rectangle) {
+	first := true
+	add := func(r Rectangle) {
+		if first {
+			bounds, first = r, false
+		} else {
+			bounds = bounds.Union(r)
+		}
+	}
+
+	for _, sh := range gn.store.shapes() {
+		add(sh.Bounds())
+	}
+
+	return
+}
+
+type DrawableElement interface {
+	Draw(c Canvas, vp Viewport)
+}
+
+// getGenerationData returns the elements of the scene in the order they are
+// drawn, the store has to be locked by the caller.
+func (gn *Generator) getGenerationData() (elements []DrawableElement) {
 	for _, bck := range gn.store.backgrounds {
 		elements = append(elements, bck)
 	}
 
-	if gn.store.brect != nil {
-		elements = append(elements, gn.store.brect)
+	if gn.store.grid.Visible {
+		elements = append(elements, gn.store.grid)
 	}
 
-	for _, tf := range gn.store.tfigures {
-		elements = append(elements, tf)
+	for _, l := range gn.store.layers {
+		switch {
+		case l.hidden || l.opacity == 0:
+		case l.opacity < 1:
+			shapes := append([]Shape(nil), l.shapes...)
+			elements = append(elements, translucentLayer{opacity: l.opacity, shapes: shapes})
+		default:
+			for _, sh := range l.shapes {
+				elements = append(elements, sh)
+			}
+		}
 	}
 
-	moves = append(moves, gn.store.moves...)
-	gn.store.moves = gn.store.moves[:0]
-
 	return
 }
 
+// blended tells whether the frame has to be blended: a visible layer is
+// translucent, or shapes have smooth strokes or text.
+func (gn *Generator) blended() bool {
+	defer gn.store.shapesM.Unlock()
+
+	gn.store.shapesM.Lock()
+
+	for _, l := range gn.store.layers {
+		if l.hidden || l.opacity == 0 {
+			continue
+		}
+
+		if l.opacity < 1 {
+			return true
+		}
+
+		for _, sh := range l.shapes {
+			switch sh := sh.(type) {
+			case *Line, *Text:
+				return true
+			case *TFigure:
+				if sh.Stroke.Width > 0 {
+					return true
+				}
+			case *BRect:
+				if sh.Stroke.Width > 0 {
+					return true
+				}
+			case *Picture:
+				if sh.Stroke.Width > 0 {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Viewport returns the transform from the canvas to an output of the size,
+// keeping the aspect of the canvas.
+func (gn *Generator) Viewport(size image.Point) Viewport {
+	defer gn.store.cameraM.Unlock()
+
+	gn.store.cameraM.Lock()
+
+	vp := NewViewport(gn.canvasBounds(size))
+	vp.Camera = gn.store.camera
+
+	return vp
+}
+
+// drawScene draws the elements with the store locked, so the operations
+// coming meanwhile wait for the frame to be done.
+func (gn *Generator) drawScene(c Canvas, vp Viewport) {
+	defer gn.store.Unlock()
+
+	gn.store.Lock()
+
+	for _, element := range gn.getGenerationData() {
+		element.Draw(c, vp)
+	}
+}
+
+func (gn *Generator) draw(c Canvas) {
+	vp := gn.Viewport(c.Bounds().Size())
+
+	gn.drawScene(c, vp)
+
+	if gn.Scale == ScaleFit {
+		drawLetterbox(c, vp.Bounds)
+	}
+
+	// overlays look at the shapes through the store, so they are drawn
+	// once it is unlocked
+	for _, overlay := range gn.Overlays {
+		overlay.Draw(c, vp)
+	}
+}
+
 func (gn *Generator) Generate(size image.Point) (screen.Texture, error) {
 	t, err := gn.Scr.NewTexture(size)
 
@@ -98,25 +429,80 @@ func (gn *Generator) Generate(size image.Point) (screen.Texture, error) {
 		return nil, err
 	}
 
-	elements, moves := gn.getGenerationData()
-
-	for _, move := range moves {
-		move.Move(t)
+	// textures can not blend translucent layers, strokes nor text, so those
+	// frames are rendered into the buffer and uploaded at once
+	if !gn.Antialias && !gn.blended() {
+		gn.draw(textureCanvas{Texture: t, scr: gn.Scr})
+		return t, nil
 	}
 
-	for _, element := range elements {
-		element.Draw(t)
+	buffer, err := gn.Scr.NewBuffer(size)
+
+	if err != nil {
+		t.Release()
+		return nil, err
 	}
 
+	// the buffer is released once the upload is done
+	defer buffer.Release()
+
+	gn.draw(ImageCanvas{RGBA: buffer.RGBA(), Antialias: gn.Antialias})
+	t.Upload(image.Point{}, buffer, buffer.Bounds())
+
 	return t, nil
 }
 
-func (gn *Generator) GetTFigures() (tfs []*TFigure) {
-	defer gn.store.tfiguresM.Unlock()
+// RenderImage draws the scene into an image of the size without a screen.
+func (gn *Generator) RenderImage(size image.Point) *image.RGBA {
+	c := NewImageCanvas(size)
+	c.Antialias = gn.Antialias
+
+	gn.draw(c)
+
+	return c.RGBA
+}
+
+// GetShapes returns the visible shapes in the order they are drawn.
+func (gn *Generator) GetShapes() (shapes []Shape) {
+	defer gn.store.shapesM.Unlock()
+
+	gn.store.shapesM.Lock()
+
+	for _, l := range gn.store.layers {
+		if !l.hidden && l.opacity > 0 {
+			shapes = append(shapes, l.shapes...)
+		}
+	}
+
+	return
+}
 
-	gn.store.tfiguresM.Lock()
+// Snapshot returns copies of the visible shapes in the order they are
+// drawn, they can be read while operations change the scene.
+func (gn *Generator) Snapshot() (shapes []Shape) {
+	defer gn.store.shapesM.Unlock()
 
-	tfs = append(tfs, gn.store.tfigures...)
+	gn.store.shapesM.Lock()
+
+	for _, l := range gn.store.layers {
+		if l.hidden || l.opacity == 0 {
+			continue
+		}
+
+		for _, sh := range l.shapes {
+			shapes = append(shapes, sh.clone())
+		}
+	}
+
+	return
+}
+
+func (gn *Generator) GetTFigures() (tfs []*TFigure) {
+	for _, sh := range gn.GetShapes() {
+		if tf, ok := sh.(*TFigure); ok {
+			tfs = append(tfs, tf)
+		}
+	}
 
 	return
 }
@@ -0,0 +1,879 @@
+package painter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"testing"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+func TestGenerator_Move(t *testing.T) {
+	gen := Generator{}
+
+	gen.Update(NewTFigure(0.25, 0.25))
+	gen.Update(NewTFigure(0.5, 0.75))
+	gen.Update(NewMove(0.1, -0.2))
+
+	expected := []Point{{0.35, 0.05}, {0.6, 0.55}}
+
+	for i, tf := range gen.GetTFigures() {
+		if math.Abs(tf.Center.X-expected[i].X) > 1e-9 || math.Abs(tf.Center.Y-expected[i].Y) > 1e-9 {
+			t.Errorf("figure %d is at %v after move, expected %v", i, tf.Center, expected[i])
+		}
+	}
+
+	gen.Update(NewMoveShape(2, -0.1, 0.2))
+
+	if tfs := gen.GetTFigures(); !near(tfs[0].Center, Point{0.35, 0.05}) || !near(tfs[1].Center, Point{0.5, 0.75}) {
+		t.Errorf("move of the second figure gives %v and %v", tfs[0].Center, tfs[1].Center)
+	}
+
+	gen.Update(NewMoveTo(0.5, 0.5))
+
+	for i, tf := range gen.GetTFigures() {
+		if tf.Center != (Point{0.5, 0.5}) {
+			t.Errorf("figure %d is at %v after moveto, expected %v", i, tf.Center, Point{0.5, 0.5})
+		}
+	}
+
+	gen.Update(Reset{})
+
+	if tfs := gen.GetTFigures(); len(tfs) != 0 {
+		t.Errorf("%d figures are left after reset", len(tfs))
+	}
+}
+
+func TestGenerator_RenderImage(t *testing.T) {
+	gen := Generator{}
+
+	gen.Update(NewWhiteFill())
+	gen.Update(NewBRect(0.1, 0.1, 0.4, 0.4))
+	gen.Update(NewTFigure(0.7, 0.7))
+
+	probes := map[Point]color.RGBA{
+		{0.25, 0.25}: {A: 0xff},
+		{0.7, 0.65}:  TFigureColor,
+		{0.7, 0.8}:   TFigureColor,
+		{0.9, 0.1}:   NewWhiteFill().Color,
+	}
+
+	for _, size := range []image.Point{{800, 800}, {120, 90}, {64, 256}} {
+		img := gen.RenderImage(size)
+		vp := gen.Viewport(size)
+
+		for p, expected := range probes {
+			if got := img.RGBAAt(vp.ToImage(p).X, vp.ToImage(p).Y); got != expected {
+				t.Errorf("size %v: point %v has color %v, expected %v", size, p, got, expected)
+			}
+		}
+	}
+}
+
+func TestGenerator_Camera(t *testing.T) {
+	gen := Generator{}
+	size := image.Pt(400, 300)
+
+	at := Point{0.3, 0.6}
+	before := gen.Viewport(size).ToImage(at)
+
+	gen.Update(NewZoom(4, &at))
+
+	if after := gen.Viewport(size).ToImage(at); after != before {
+		t.Errorf("zoom moved its center from %v to %v", before, after)
+	}
+
+	gen.Update(NewPan(0.25, 0))
+
+	if after := gen.Viewport(size).ToImage(at); after.X-before.X != 100 || after.Y != before.Y {
+		t.Errorf("pan by a quarter of the view moved the point from %v to %v", before, after)
+	}
+
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(Fit{})
+
+	vp := gen.Viewport(size)
+	bounds := vp.ToImageRect(gen.GetTFigures()[0].Bounds())
+
+	if !bounds.In(vp.Bounds) || bounds.Dx() < size.X/2 {
+		t.Errorf("figure takes %v of %v after fit", bounds, vp.Bounds)
+	}
+
+	if p := vp.ToCanvas(vp.ToImage(Point{0.5, 0.5})); math.Abs(p.X-0.5) > 0.01 || math.Abs(p.Y-0.5) > 0.01 {
+		t.Errorf("point 0.5 0.5 is mapped back to %v", p)
+	}
+}
+
+func TestGenerator_Transform(t *testing.T) {
+	gen := Generator{}
+
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewTFigure(0.25, 0.25))
+
+	tfs := gen.GetTFigures()
+	if tfs[0].ID != 1 || tfs[1].ID != 2 {
+		t.Fatalf("figures got ids %d and %d, expected 1 and 2", tfs[0].ID, tfs[1].ID)
+	}
+
+	// the stem of the upright figure points down, after the turn it points left
+	gen.Update(NewRotate(1, 90, nil))
+
+	if tfs[0].Contains(Point{0.5, 0.6}) || !tfs[0].Contains(Point{0.4, 0.5}) {
+		t.Errorf("figure is not turned by 90 degrees")
+	}
+
+	gen.Update(NewScale(2, 2, 2, nil))
+
+	if bounds := tfs[1].Bounds(); math.Abs(bounds.Max.X-bounds.Min.X-0.5) > 1e-9 {
+		t.Errorf("figure is %v wide after scaling, expected 0.5", bounds.Max.X-bounds.Min.X)
+	}
+
+	gen.Update(NewTransform(2, Translation(Point{0.25, 0})))
+
+	if tfs[1].Center != (Point{0.5, 0.25}) {
+		t.Errorf("figure is at %v after translation, expected %v", tfs[1].Center, Point{0.5, 0.25})
+	}
+
+	img := gen.RenderImage(image.Pt(100, 100))
+
+	if got := img.RGBAAt(40, 50); got != TFigureColor {
+		t.Errorf("turned stem is not drawn, got %v", got)
+	}
+}
+
+func TestGenerator_Resize(t *testing.T) {
+	gen := Generator{}
+
+	gen.Update(NewCustomTFigure(0.5, 0.5, 0.1, 0.1, TFigureColor))
+	tf := gen.GetTFigures()[0]
+
+	if tf.Contains(Point{0.5, 0.6}) {
+		t.Errorf("small figure contains a point out of it")
+	}
+
+	gen.Update(NewResize(1, 0.4, 0.4))
+
+	for _, p := range []Point{{0.5, 0.6}, {0.32, 0.4}} {
+		if !tf.Contains(p) {
+			t.Errorf("resized figure does not contain %v", p)
+		}
+	}
+
+	img := gen.RenderImage(image.Pt(100, 100))
+
+	if got := img.RGBAAt(50, 65); got != TFigureColor {
+		t.Errorf("resized figure is not drawn where it is hit, got %v", got)
+	}
+}
+
+func TestGenerator_Text(t *testing.T) {
+	gen := Generator{}
+
+	gen.Update(NewWhiteFill())
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewText(0.1, 0.1, "label", 0.1, TextColor))
+	gen.Update(NewMove(0.1, 0))
+
+	shapes := gen.GetShapes()
+	txt, ok := shapes[1].(*Text)
+
+	if !ok || txt.GetID() != 2 {
+		t.Fatalf("text is not stored as the second shape")
+	}
+
+	if txt.Position != (Point{0.2, 0.1}) {
+		t.Errorf("text is at %v after move, expected %v", txt.Position, Point{0.2, 0.1})
+	}
+
+	bounds := txt.Bounds()
+	if !txt.Contains(Point{0.25, 0.15}) || bounds.Max.X < 0.35 || bounds.Max.Y < 0.19 {
+		t.Errorf("text of size 0.1 takes only %v", bounds)
+	}
+
+	img := gen.RenderImage(image.Pt(200, 200))
+	vp := gen.Viewport(image.Pt(200, 200))
+	r := vp.ToImageRect(bounds)
+
+	inked := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.RGBAAt(x, y) != NewWhiteFill().Color {
+				inked++
+			}
+		}
+	}
+
+	if inked == 0 {
+		t.Errorf("text is not drawn")
+	}
+}
+
+func TestGenerator_Picture(t *testing.T) {
+	// left half is blue, right half is transparent
+	asset := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	blue := color.RGBA{B: 0xff, A: 0xff}
+	draw.Draw(asset, image.Rect(0, 0, 2, 4), &image.Uniform{C: blue}, image.Point{}, draw.Src)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, asset); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := Generator{}
+
+	if err := gen.AddAsset("../secret.png", bytes.NewReader(encoded.Bytes())); err == nil {
+		t.Errorf("asset name with a path is accepted")
+	}
+
+	if err := gen.AddAsset("half.png", &encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	gen.Update(NewWhiteFill())
+	gen.Update(NewPicture("half.png", 0.2, 0.2, 0.4, 0.4))
+	gen.Update(NewPicture("missing.png", 0.7, 0.7, 0.2, 0.2))
+
+	for i := 0; i < 2; i++ {
+		img := gen.RenderImage(image.Pt(100, 100))
+
+		probes := map[image.Point]color.RGBA{
+			{25, 50}: blue,
+			{55, 50}: NewWhiteFill().Color,
+			{80, 80}: missingAssetColor,
+		}
+
+		for p, expected := range probes {
+			if got := img.RGBAAt(p.X, p.Y); got != expected {
+				t.Errorf("frame %d: pixel %v is %v, expected %v", i, p, got, expected)
+			}
+		}
+	}
+
+	half := gen.assets.assets["half.png"]
+
+	if sp := half.sprites[image.Pt(40, 40)]; sp == nil || len(half.sprites) != 1 {
+		t.Errorf("asset has to keep the sprite of the size it is drawn at")
+	}
+
+	// two pictures of the same asset keep their sprites across the frames
+	gen.Update(NewPicture("half.png", 0, 0, 0.2, 0.2))
+	gen.RenderImage(image.Pt(100, 100))
+	sp := half.sprites[image.Pt(20, 20)]
+	gen.RenderImage(image.Pt(100, 100))
+
+	if len(half.sprites) != 2 || half.sprites[image.Pt(20, 20)] != sp {
+		t.Errorf("pictures of different sizes have to keep sprites of their own")
+	}
+
+	for i := 1; i <= spritesPerAsset; i++ {
+		gen.RenderImage(image.Pt(100+i, 100+i))
+	}
+
+	if len(half.sprites) > spritesPerAsset {
+		t.Errorf("asset keeps %d sprites, expected at most %d", len(half.sprites), spritesPerAsset)
+	}
+
+	gen.SetAssetDir(t.TempDir())
+	gen.RenderImage(image.Pt(50, 50))
+
+	if a, ok := gen.assets.assets["missing.png"]; !ok || a.err == nil {
+		t.Errorf("asset missing from the directory has to be remembered")
+	}
+
+	// a tiny file claiming a huge image is rejected before decoding
+	encoded.Reset()
+	if err := png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 5000, 5000))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gen.AddAsset("huge.png", &encoded); err == nil {
+		t.Errorf("asset with too many pixels is accepted")
+	}
+}
+
+func TestGenerator_MoveTo(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.2, 0.2))
+	gen.Update(NewBRect(0.1, 0.1, 0.3, 0.2))
+	gen.Update(NewText(0.6, 0.6, "text", 0.05, TextColor))
+	gen.Update(NewPicture("missing.png", 0.7, 0.1, 0.2, 0.1))
+	gen.Update(NewLine(0.1, 0.9, 0.5, 0.7, 0.01, nil, StrokeColor))
+
+	dest := Point{X: 0.4, Y: 0.6}
+
+	// every kind of shape is put on the point by the middle of its bounds
+	for id := 1; id <= 5; id++ {
+		gen.Update(NewMoveShapeTo(id, dest.X, dest.Y))
+
+		sh, _ := gen.findShape(id)
+
+		if c := sh.Bounds().Center(); !near(c, dest) {
+			t.Errorf("%T is at %v after moveto, expected %v", sh, c, dest)
+		}
+	}
+
+	gen.Update(NewGroup("pair", []int{1, 2}, nil))
+	gen.Update(NewMoveShapeTo(1, 0.5, 0.5))
+
+	first, _ := gen.findShape(1)
+	second, _ := gen.findShape(2)
+
+	if c := boundsOf([]Shape{first, second}).Center(); !near(c, Point{0.5, 0.5}) {
+		t.Errorf("group is at %v after moveto, expected %v", c, Point{0.5, 0.5})
+	}
+
+	gen.Update(NewMoveTo(0.3, 0.7))
+
+	// the black rectangle stays in place when all of the shapes are moved
+	for _, sh := range gen.GetShapes() {
+		if _, ok := sh.(*BRect); ok {
+			continue
+		}
+
+		if c := sh.Bounds().Center(); !near(c, Point{0.3, 0.7}) {
+			t.Errorf("%T is at %v after moveto of all shapes", sh, c)
+		}
+	}
+}
+
+func TestGenerator_Gradient(t *testing.T) {
+	black, white := color.RGBA{A: 0xff}, NewWhiteFill().Color
+
+	gen := Generator{}
+
+	gen.Update(NewGradientFill(0, Gradient{
+		Kind: LinearGradient, From: Point{0, 0}, To: Point{1, 0}, Stops: []color.RGBA{black, white},
+	}))
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewGradientFill(1, Gradient{
+		Kind: RadialGradient, From: Point{0.5, 0.5}, Radius: 0.5, Stops: []color.RGBA{white, black},
+	}))
+	gen.Update(NewMove(0.25, 0))
+
+	img := gen.RenderImage(image.Pt(100, 100))
+
+	if left, right := img.RGBAAt(0, 0), img.RGBAAt(99, 0); left.R > 0x08 || right.R < 0xf7 {
+		t.Errorf("background goes from %v to %v, expected black to white", left, right)
+	}
+
+	if middle := img.RGBAAt(50, 5); middle.R < 0x70 || middle.R > 0x90 {
+		t.Errorf("background in the middle is %v, expected gray", middle)
+	}
+
+	// the gradient of the figure moves together with it
+	if center := img.RGBAAt(75, 50); center.R < 0xe0 {
+		t.Errorf("figure center is %v, expected almost white", center)
+	}
+
+	if corner := img.RGBAAt(63, 38); corner.R > 0x40 {
+		t.Errorf("figure corner is %v, expected almost black", corner)
+	}
+}
+
+func TestGenerator_GradientShapes(t *testing.T) {
+	black, white := color.RGBA{A: 0xff}, NewWhiteFill().Color
+
+	gen := Generator{}
+
+	gen.Update(NewBRect(0, 0, 0.5, 0.5))
+	gen.Update(NewGradientFill(1, Gradient{
+		Kind: LinearGradient, From: Point{0, 0}, To: Point{1, 0}, Stops: []color.RGBA{black, white},
+	}))
+
+	gen.Update(NewText(0.6, 0.6, "text", 12, black))
+	gen.Update(NewGradientFill(2, Gradient{
+		Kind: LinearGradient, From: Point{0, 0}, To: Point{1, 0}, Stops: []color.RGBA{black, white},
+	}))
+
+	img := gen.RenderImage(image.Pt(100, 100))
+
+	// the gradient spans the rectangle, not the whole canvas
+	if left, right := img.RGBAAt(1, 25), img.RGBAAt(48, 25); left.R > 0x10 || right.R < 0xe0 {
+		t.Errorf("rectangle goes from %v to %v, expected black to white", left, right)
+	}
+
+	if txt, ok := gen.GetShapes()[1].(*Text); !ok || txt.Color != black {
+		t.Errorf("text is changed by the gradient")
+	}
+}
+
+func TestGenerator_Stroke(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	white := NewWhiteFill().Color
+
+	gen := Generator{}
+
+	gen.Update(NewWhiteFill())
+	gen.Update(NewLine(0.1, 0.1, 0.9, 0.9, 0.02, nil, red))
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewText(0.1, 0.8, "label", TextSize, TextColor))
+	gen.Update(NewSetStroke(2, 0.02, nil, StrokeColor))
+	gen.Update(NewSetStroke(3, 0.02, nil, StrokeColor))
+
+	shapes := gen.GetShapes()
+
+	if ln := shapes[0].(*Line); !ln.Contains(Point{0.2, 0.205}) || ln.Contains(Point{0.2, 0.25}) {
+		t.Errorf("line of width 0.02 is hit wrongly")
+	}
+
+	img := gen.RenderImage(image.Pt(100, 100))
+
+	probes := map[image.Point]color.RGBA{
+		// line
+		{20, 20}: red,
+		{20, 30}: white,
+		// figure border and inside
+		{37, 37}: StrokeColor,
+		{50, 40}: TFigureColor,
+		{70, 58}: white,
+	}
+
+	for p, expected := range probes {
+		if got := img.RGBAAt(p.X, p.Y); got != expected {
+			t.Errorf("pixel %v is %v, expected %v", p, got, expected)
+		}
+	}
+
+	// the edge of the line is blended with the background
+	if edge := img.RGBAAt(20, 21); edge == red || edge == white {
+		t.Errorf("edge of the line is %v, expected a blend", edge)
+	}
+
+	// a dashed border skips every other piece of the top side
+	gen.Update(NewSetStroke(2, 0.02, []float64{0.05}, StrokeColor))
+	img = gen.RenderImage(image.Pt(100, 100))
+
+	if drawn, skipped := img.RGBAAt(40, 37), img.RGBAAt(45, 37); drawn != StrokeColor || skipped == StrokeColor {
+		t.Errorf("dashed border gives %v and %v", drawn, skipped)
+	}
+}
+
+func TestGenerator_Antialias(t *testing.T) {
+	white := NewWhiteFill().Color
+
+	for _, antialias := range []bool{false, true} {
+		gen := Generator{Antialias: antialias}
+
+		gen.Update(NewWhiteFill())
+		gen.Update(NewTFigure(0.5, 0.5))
+		gen.Update(NewRotate(1, 30, nil))
+
+		img := gen.RenderImage(image.Pt(100, 100))
+
+		if got := img.RGBAAt(50, 45); got != TFigureColor {
+			t.Errorf("antialias %v: inside of the figure is %v", antialias, got)
+		}
+
+		blended := 0
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 100; x++ {
+				if c := img.RGBAAt(x, y); c != white && c != TFigureColor {
+					blended++
+				}
+			}
+		}
+
+		if antialias && blended == 0 {
+			t.Errorf("edges of the figure are not blended")
+		}
+
+		if !antialias && blended != 0 {
+			t.Errorf("%d pixels are blended without antialiasing", blended)
+		}
+	}
+}
+
+// fillTexture paints the fills of a texture into an image and counts them.
+type fillTexture struct {
+	screen.Texture
+
+	img   *image.RGBA
+	fills int
+}
+
+func (ft *fillTexture) Bounds() image.Rectangle {
+	return ft.img.Bounds()
+}
+
+func (ft *fillTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(ft.img, dr, &image.Uniform{C: src}, image.Point{}, op)
+	ft.fills++
+}
+
+func TestGenerator_TextureMask(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewWhiteFill())
+	gen.Update(NewLine(0.1, 0.1, 0.9, 0.7, 0.05, nil, StrokeColor))
+	gen.Update(NewLine(0.2, 0.9, 0.8, 0.9, 0.05, nil, StrokeColor))
+
+	expected := NewImageCanvas(image.Pt(100, 100))
+	expected.Antialias = true
+	gen.draw(expected)
+
+	ft := &fillTexture{img: image.NewRGBA(image.Rect(0, 0, 100, 100))}
+	gen.draw(textureCanvas{Texture: ft})
+
+	partial := 0
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			want, got := expected.RGBAAt(x, y), ft.img.RGBAAt(x, y)
+
+			if d := math.Abs(float64(want.R) - float64(got.R)); d > 0x100/maskLevels {
+				t.Fatalf("pixel %v is %v on the texture, expected about %v", image.Pt(x, y), got, want)
+			}
+
+			if want != NewWhiteFill().Color && want != StrokeColor {
+				partial++
+			}
+		}
+	}
+
+	if ft.fills >= partial {
+		t.Errorf("%d fills are used for %d pixels blended", ft.fills, partial)
+	}
+}
+
+// imageScreen makes buffers and textures backed by images.
+type imageScreen struct {
+	screen.Screen
+}
+
+type imageBuffer struct {
+	img *image.RGBA
+}
+
+func (b imageBuffer) Release()                {}
+func (b imageBuffer) Size() image.Point       { return b.img.Bounds().Size() }
+func (b imageBuffer) Bounds() image.Rectangle { return b.img.Bounds() }
+func (b imageBuffer) RGBA() *image.RGBA       { return b.img }
+
+func (imageScreen) NewBuffer(size image.Point) (screen.Buffer, error) {
+	return imageBuffer{img: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+func (imageScreen) NewTexture(size image.Point) (screen.Texture, error) {
+	return &imageTexture{fillTexture{img: image.NewRGBA(image.Rectangle{Max: size})}}, nil
+}
+
+// imageTexture keeps what is uploaded to it.
+type imageTexture struct {
+	fillTexture
+}
+
+func (it *imageTexture) Release()          {}
+func (it *imageTexture) Size() image.Point { return it.img.Bounds().Size() }
+
+func (it *imageTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	draw.Draw(it.img, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+}
+
+func TestGenerator_GenerateMatchesImage(t *testing.T) {
+	gen := Generator{Scr: imageScreen{}}
+	gen.Update(NewWhiteFill())
+	gen.Update(NewTFigure(0.4, 0.4))
+	gen.Update(NewLine(0.1, 0.1, 0.9, 0.7, 0.02, nil, StrokeColor))
+	gen.Update(NewText(0.1, 0.8, "text", 0.05, TextColor))
+
+	size := image.Pt(100, 100)
+	expected := gen.RenderImage(size)
+
+	tx, err := gen.Generate(size)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := tx.(*imageTexture).img
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			if want, got := expected.RGBAAt(x, y), got.RGBAAt(x, y); want != got {
+				t.Fatalf("pixel %v is %v in the window, %v in the image", image.Pt(x, y), got, want)
+			}
+		}
+	}
+}
+
+func TestGenerator_Layers(t *testing.T) {
+	red, blue := color.RGBA{R: 0xff, A: 0xff}, color.RGBA{B: 0xff, A: 0xff}
+	white := NewWhiteFill().Color
+	under := -1
+
+	gen := Generator{}
+
+	gen.Update(NewWhiteFill())
+	gen.Update(NewCustomTFigure(0.5, 0.5, 0.5, 0.5, red))
+	gen.Update(NewLayer("bottom", &under))
+	gen.Update(NewCustomTFigure(0.5, 0.5, 0.5, 0.5, blue))
+	gen.Update(NewBRect(0.4, 0.4, 0.6, 0.6))
+
+	pixel := func() color.RGBA {
+		return gen.RenderImage(image.Pt(100, 100)).RGBAAt(50, 40)
+	}
+
+	// the layer below the default one is covered by the red figure
+	if got := pixel(); got != red {
+		t.Errorf("pixel is %v, expected the red figure on top", got)
+	}
+
+	gen.Update(NewHideLayer(DefaultLayer))
+
+	if got := pixel(); got != blue {
+		t.Errorf("pixel is %v, expected the blue figure once the default layer is hidden", got)
+	}
+
+	if shapes := gen.GetShapes(); len(shapes) != 2 || shapes[0].GetID() != 3 {
+		t.Errorf("visible shapes are %v, expected the black rectangle below the blue figure", shapes)
+	}
+
+	gen.Update(NewBringToFront(3))
+
+	if got := pixel(); got != (color.RGBA{A: 0xff}) {
+		t.Errorf("pixel is %v, expected the black rectangle brought to front", got)
+	}
+
+	gen.Update(NewSendToBack(3))
+	gen.Update(NewShowLayer(DefaultLayer))
+	gen.Update(NewLayerOpacity(DefaultLayer, 0.5))
+
+	if got := pixel(); got.R < 0x70 || got.R > 0x90 || got.B < 0x70 || got.B > 0x90 {
+		t.Errorf("pixel is %v, expected red blended with blue", got)
+	}
+
+	gen.Update(NewLayerOpacity(DefaultLayer, 0))
+	gen.Update(NewHideLayer("bottom"))
+
+	if got := pixel(); got != white {
+		t.Errorf("pixel is %v, expected the background with every layer hidden", got)
+	}
+
+	gen.Update(Reset{})
+	gen.Update(NewTFigure(0.5, 0.5))
+
+	if shapes := gen.GetShapes(); len(shapes) != 1 {
+		t.Errorf("%d shapes are visible after reset", len(shapes))
+	}
+}
+
+func TestGenerator_Group(t *testing.T) {
+	gen := Generator{}
+
+	gen.Update(NewTFigure(0.25, 0.5))
+	gen.Update(NewTFigure(0.75, 0.5))
+	gen.Update(NewText(0.45, 0.45, "label", TextSize, TextColor))
+	gen.Update(NewTFigure(0.5, 0.9))
+	gen.Update(NewGroup("figures", []int{1, 2}, nil))
+	gen.Update(NewGroup("all", []int{3}, []string{"figures"}))
+	gen.Update(NewGroup("figures", nil, []string{"all"}))
+
+	// the group turns around the center of its bounds
+	gen.Update(NewRotate(2, 180, nil))
+
+	tfs := gen.GetTFigures()
+	if !near(tfs[0].Center, Point{0.75, 0.5}) || !near(tfs[1].Center, Point{0.25, 0.5}) {
+		t.Errorf("figures are at %v and %v after the group is rotated", tfs[0].Center, tfs[1].Center)
+	}
+
+	if !near(tfs[2].Center, Point{0.5, 0.9}) {
+		t.Errorf("figure out of the group is moved to %v", tfs[2].Center)
+	}
+
+	if group := gen.GroupShapes(tfs[0]); len(group) != 3 {
+		t.Errorf("%d shapes are dragged together with a grouped figure", len(group))
+	}
+
+	gen.Update(NewUngroup("all"))
+
+	if group := gen.GroupShapes(tfs[0]); len(group) != 2 {
+		t.Errorf("%d shapes are dragged together after ungroup", len(group))
+	}
+
+	gen.Update(NewDelete(1))
+
+	if shapes := gen.GetShapes(); len(shapes) != 2 || shapes[0].GetID() != 3 || shapes[1].GetID() != 4 {
+		t.Errorf("shapes left after the group is deleted are %v", shapes)
+	}
+
+	gen.Update(NewGroup("rest", []int{3, 4}, nil))
+	gen.Update(Reset{})
+	gen.Update(NewTFigure(0.5, 0.5))
+
+	if group := gen.GroupShapes(gen.GetShapes()[0]); len(group) != 1 {
+		t.Errorf("groups are left after reset")
+	}
+}
+
+func TestGenerator_Grid(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewWhiteFill())
+	gen.Update(NewSetGrid(0.1))
+
+	img := gen.RenderImage(image.Pt(100, 100))
+
+	if got := img.RGBAAt(30, 55); got == (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("grid line has to be drawn over the background")
+	}
+
+	if got := img.RGBAAt(35, 55); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("background between the lines is %v", got)
+	}
+
+	gen.Update(SetGrid{})
+
+	if gen.Grid().Visible {
+		t.Errorf("grid has to be hidden by the second grid command")
+	}
+
+	gen.Update(ToggleSnap{})
+	gen.Update(NewTFigure(0.43, 0.57))
+	gen.Update(NewBRect(0.01, 0.02, 0.26, 0.33))
+
+	if tf := gen.GetTFigures()[0]; !near(tf.Center, Point{0.4, 0.6}) {
+		t.Errorf("figure is put at %v, expected %v", tf.Center, Point{0.4, 0.6})
+	}
+
+	brect := gen.GetShapes()[0].(*BRect)
+
+	if !near(brect.Rect.Min, Point{0, 0}) || !near(brect.Rect.Max, Point{0.3, 0.3}) {
+		t.Errorf("rectangle is put at %v", brect.Rect)
+	}
+
+	gen.Update(NewMoveTo(0.77, 0.22))
+
+	if tf := gen.GetTFigures()[0]; !near(tf.Center, Point{0.8, 0.2}) {
+		t.Errorf("figure is moved to %v, expected %v", tf.Center, Point{0.8, 0.2})
+	}
+
+	gen.Update(Reset{})
+
+	if gen.Grid() != (Grid{}) {
+		t.Errorf("reset has to drop the grid")
+	}
+}
+
+func TestGenerator_AlignDistribute(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewCustomTFigure(0.1, 0.5, 0.1, 0.1, TFigureColor))
+	gen.Update(NewCustomTFigure(0.3, 0.2, 0.2, 0.2, TFigureColor))
+	gen.Update(NewCustomTFigure(0.9, 0.7, 0.1, 0.1, TFigureColor))
+
+	tfs := gen.GetTFigures()
+	first, last := tfs[0].Bounds(), tfs[2].Bounds()
+
+	gen.Update(NewDistribute(Horizontal, []int{3, 1, 2}))
+
+	b := []Rectangle{tfs[0].Bounds(), tfs[1].Bounds(), tfs[2].Bounds()}
+
+	if !near(b[0].Min, first.Min) || !near(b[2].Min, last.Min) {
+		t.Errorf("outermost figures have to stay in place, they are at %v and %v", b[0], b[2])
+	}
+
+	if gap1, gap2 := b[1].Min.X-b[0].Max.X, b[2].Min.X-b[1].Max.X; math.Abs(gap1-gap2) > 1e-9 {
+		t.Errorf("gaps between the figures are %v and %v", gap1, gap2)
+	}
+
+	gen.Update(NewAlign(AlignTop, []int{1, 2, 3}))
+
+	for _, tf := range tfs {
+		if top := tf.Bounds().Min.Y; math.Abs(top-b[1].Min.Y) > 1e-9 {
+			t.Errorf("figure %d has the top at %v, expected %v", tf.GetID(), top, b[1].Min.Y)
+		}
+	}
+
+	// a group is lined up as a whole
+	gen.Update(NewGroup("pair", []int{2, 3}, nil))
+	apart := tfs[2].Center.X - tfs[1].Center.X
+
+	gen.Update(NewAlign(AlignRight, []int{1, 3}))
+
+	if right := tfs[2].Bounds().Max.X; math.Abs(right-last.Max.X) > 1e-9 {
+		t.Errorf("group has the right side at %v, expected %v", right, last.Max.X)
+	}
+
+	if right := tfs[0].Bounds().Max.X; math.Abs(right-last.Max.X) > 1e-9 {
+		t.Errorf("figure has the right side at %v, expected %v", right, last.Max.X)
+	}
+
+	if got := tfs[2].Center.X - tfs[1].Center.X; math.Abs(got-apart) > 1e-9 {
+		t.Errorf("figures of the group are %v apart, expected %v", got, apart)
+	}
+}
+
+func TestGenerator_Clone(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewBRect(0.1, 0.1, 0.2, 0.2))
+	gen.Update(NewLayer("top", nil))
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewLayer("default", nil))
+	gen.Update(NewText(0.1, 0.8, "label", TextSize, TextColor))
+
+	gen.Update(NewClone(2, 0.1, -0.1))
+	gen.Update(NewClone(1, 0, 0))
+	gen.Update(NewClone(7, 0, 0))
+
+	tfs := gen.GetTFigures()
+
+	if len(tfs) != 2 || !near(tfs[0].Center, Point{0.5, 0.5}) || !near(tfs[1].Center, Point{0.6, 0.4}) {
+		t.Fatalf("figure has to be cloned next to itself, got %d figures", len(tfs))
+	}
+
+	if tfs[1].ID != 4 {
+		t.Errorf("clone has the id %d, expected %d", tfs[1].ID, 4)
+	}
+
+	// the clone goes on top of the layer of the shape
+	if shapes := gen.GetShapes(); len(shapes) != 4 || shapes[3].GetID() != 4 {
+		t.Errorf("shapes after cloning are %v", shapes)
+	}
+
+	gen.Update(NewMoveShape(4, 0.1, 0))
+
+	if !near(tfs[0].Center, Point{0.5, 0.5}) {
+		t.Errorf("moving the clone moves the figure to %v", tfs[0].Center)
+	}
+}
+
+func TestGenerator_Letterbox(t *testing.T) {
+	gen := Generator{Aspect: 1, Scale: ScaleFit}
+	gen.Update(NewWhiteFill())
+
+	wide, tall := image.Pt(200, 100), image.Pt(100, 200)
+
+	if got := gen.Viewport(wide).Bounds; got != image.Rect(50, 0, 150, 100) {
+		t.Errorf("canvas fits into %v of a wide window", got)
+	}
+
+	if got := gen.Viewport(tall).Bounds; got != image.Rect(0, 50, 100, 150) {
+		t.Errorf("canvas fits into %v of a tall window", got)
+	}
+
+	img := gen.RenderImage(wide)
+
+	if got := img.RGBAAt(10, 50); got != LetterboxColor {
+		t.Errorf("bar beside the canvas is %v", got)
+	}
+
+	if got := img.RGBAAt(100, 50); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("canvas is %v", got)
+	}
+
+	gen.Scale = ScaleFill
+
+	if got := gen.Viewport(wide).Bounds; got != image.Rect(0, -50, 200, 150) {
+		t.Errorf("canvas fills %v of a wide window", got)
+	}
+
+	gen.Scale = ScaleStretch
+
+	if got := gen.Viewport(wide).Bounds; got != image.Rect(0, 0, 200, 100) {
+		t.Errorf("canvas is stretched to %v", got)
+	}
+}
+
+func near(p, q Point) bool {
+	return math.Abs(p.X-q.X) < 1e-9 && math.Abs(p.Y-q.Y) < 1e-9
+}
@@ -0,0 +1,239 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"strconv"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+type GradientKind int
+
+const (
+	LinearGradient GradientKind = iota
+	RadialGradient
+)
+
+func (k GradientKind) String() string {
+	if k == RadialGradient {
+		return "radial"
+	}
+
+	return "linear"
+}
+
+// MaxGradientStops limits the amount of colors of a gradient.
+const MaxGradientStops = 16
+
+// Gradient blends its color stops, spread evenly, along the line from From
+// to To or, when it is radial, from the center From out to the Radius.
+// Coordinates are fractions of the bounds of the painted area, so the
+// gradient follows a shape around.
+type Gradient struct {
+	Kind   GradientKind
+	From   Point
+	To     Point
+	Radius float64
+	Stops  []color.RGBA
+}
+
+func (g Gradient) String() string {
+	s := g.Kind.String() + " " + formatFloat(g.From.X) + " " + formatFloat(g.From.Y)
+
+	if g.Kind == RadialGradient {
+		s += " " + formatFloat(g.Radius)
+	} else {
+		s += " " + formatFloat(g.To.X) + " " + formatFloat(g.To.Y)
+	}
+
+	for _, stop := range g.Stops {
+		s += " " + formatColor(stop)
+	}
+
+	return s
+}
+
+// at returns the color of the gradient at u given relative to the bounds.
+func (g *Gradient) at(u Point) color.RGBA {
+	switch len(g.Stops) {
+	case 0:
+		return color.RGBA{}
+	case 1:
+		return g.Stops[0]
+	}
+
+	t := 0.0
+
+	if g.Kind == RadialGradient {
+		if g.Radius > 0 {
+			t = math.Hypot(u.X-g.From.X, u.Y-g.From.Y) / g.Radius
+		}
+	} else {
+		d := Point{X: g.To.X - g.From.X, Y: g.To.Y - g.From.Y}
+
+		if l := d.X*d.X + d.Y*d.Y; l > 0 {
+			t = ((u.X-g.From.X)*d.X + (u.Y-g.From.Y)*d.Y) / l
+		}
+	}
+
+	t = math.Min(math.Max(t, 0), 1) * float64(len(g.Stops)-1)
+	i := min(int(t), len(g.Stops)-2)
+
+	return lerpColor(g.Stops[i], g.Stops[i+1], t-float64(i))
+}
+
+func lerpColor(a, b color.RGBA, t float64) color.RGBA {
+	lerp := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+
+	return color.RGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: lerp(a.A, b.A)}
+}
+
+// gradientImage renders the gradient over the area of pixels, box holds the
+// bounds the gradient coordinates are relative to, in pixels.
+func gradientImage(g *Gradient, box Rectangle, area image.Rectangle) *image.RGBA {
+	relative := func(v, min, max float64) float64 {
+		if max == min {
+			return 0
+		}
+		return (v - min) / (max - min)
+	}
+
+	img := image.NewRGBA(area)
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		uy := relative(float64(y)+0.5, box.Min.Y, box.Max.Y)
+
+		for x := area.Min.X; x < area.Max.X; x++ {
+			ux := relative(float64(x)+0.5, box.Min.X, box.Max.X)
+			img.SetRGBA(x, y, g.at(Point{X: ux, Y: uy}))
+		}
+	}
+
+	return img
+}
+
+// paintPolygons paints the gradient over the polygons given in canvas
+// coordinates, their edges are smooth on antialiased canvases.
+func paintPolygons(c Canvas, vp Viewport, polygons [][]Point, g *Gradient, box Rectangle) {
+	if ic, ok := c.(ImageCanvas); ok && ic.Antialias {
+		mask := polygonMask(c, vp, polygons)
+
+		if mask != nil {
+			area := mask.Bounds()
+			draw.DrawMask(ic.RGBA, area, gradientImage(g, box, area), area.Min, mask, area.Min, draw.Over)
+		}
+
+		return
+	}
+
+	var spans []image.Rectangle
+
+	for _, polygon := range polygons {
+		spans = append(spans, polygonSpans(c, vp, polygon)...)
+	}
+
+	paintSpans(c, g, box, spans)
+}
+
+// paintSpans paints the gradient over the spans of pixels.
+func paintSpans(c Canvas, g *Gradient, box Rectangle, spans []image.Rectangle) {
+	area := image.Rectangle{}
+
+	for _, span := range spans {
+		area = area.Union(span)
+	}
+
+	if area.Empty() {
+		return
+	}
+
+	img := gradientImage(g, box, area)
+
+	switch c := c.(type) {
+	case ImageCanvas:
+		for _, span := range spans {
+			draw.Draw(c.RGBA, span, img, span.Min, draw.Src)
+		}
+
+	case textureCanvas:
+		buffer, err := c.scr.NewBuffer(area.Size())
+
+		if err != nil {
+			log.Printf("cannot upload a gradient: %s", err)
+			return
+		}
+
+		defer buffer.Release()
+
+		draw.Draw(buffer.RGBA(), buffer.Bounds(), img, area.Min, draw.Src)
+
+		for _, span := range spans {
+			c.Upload(span.Min, buffer, span.Sub(area.Min))
+		}
+
+	default:
+		for _, span := range spans {
+			fill(c, span, img.RGBAAt((span.Min.X+span.Max.X)/2, span.Min.Y), screen.Src)
+		}
+	}
+}
+
+// pixelBounds returns r mapped to pixels without rounding.
+func pixelBounds(vp Viewport, r Rectangle) Rectangle {
+	return Rectangle{Min: vp.toPixel(r.Min), Max: vp.toPixel(r.Max)}
+}
+
+// gradientFilled is implemented by shapes able to be painted with a
+// gradient, pictures, text and lines are not.
+type gradientFilled interface {
+	setGradient(g *Gradient)
+}
+
+// GradientFill paints the figure or the rectangle with the ID with the
+// gradient, or the whole background when the ID is zero.
+type GradientFill struct {
+	ID       int
+	Gradient Gradient
+}
+
+func (gf GradientFill) String() string {
+	s := "gradient "
+
+	if gf.ID != 0 {
+		s += strconv.Itoa(gf.ID) + " "
+	}
+
+	return s + gf.Gradient.String()
+}
+
+func (gf GradientFill) MarshalText() ([]byte, error) {
+	return []byte(gf.String()), nil
+}
+
+func (gf *GradientFill) Draw(c Canvas, vp Viewport) {
+	canvas := Rectangle{Max: Point{X: 1, Y: 1}}
+	paintSpans(c, &gf.Gradient, pixelBounds(vp, canvas), []image.Rectangle{c.Bounds()})
+}
+
+func NewGradientFill(id int, g Gradient) GradientFill {
+	g.Stops = append([]color.RGBA(nil), g.Stops...)
+	return GradientFill{ID: id, Gradient: g}
+}
+
+// ParseGradientKind returns the kind of gradient with the name.
+func ParseGradientKind(name string) (GradientKind, bool) {
+	switch name {
+	case "linear":
+		return LinearGradient, true
+	case "radial":
+		return RadialGradient, true
+	}
+
+	return LinearGradient, false
+}
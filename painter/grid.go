@@ -0,0 +1,125 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// GridColor is premultiplied by its alpha.
+var GridColor = color.RGBA{R: 0x30, G: 0x30, B: 0x30, A: 0x50}
+
+// DefaultGridSpacing is used until the spacing is given.
+const DefaultGridSpacing = 0.05
+
+// Grid lines are not drawn closer than this many pixels to each other.
+const minGridStep = 4
+
+// Grid lines up the canvas at multiples of the Spacing. Figures and the
+// corners of rectangles are put on the nearest crossing when Snap is set.
+type Grid struct {
+	Spacing float64
+	Visible bool
+	Snap    bool
+}
+
+func (g Grid) spacing() float64 {
+	if g.Spacing == 0 {
+		return DefaultGridSpacing
+	}
+
+	return g.Spacing
+}
+
+// SnapPoint returns the crossing of the grid nearest to p, or p itself
+// when snapping is off.
+func (g Grid) SnapPoint(p Point) Point {
+	if !g.Snap {
+		return p
+	}
+
+	s := g.spacing()
+
+	return Point{X: math.Round(p.X/s) * s, Y: math.Round(p.Y/s) * s}
+}
+
+func (g Grid) Draw(c Canvas, vp Viewport) {
+	s := g.spacing()
+
+	if s/vp.PixelSize() < minGridStep {
+		return
+	}
+
+	bounds := c.Bounds()
+	from, to := vp.ToCanvas(bounds.Min), vp.ToCanvas(bounds.Max)
+
+	for i := math.Ceil(from.X / s); i*s < to.X; i++ {
+		x := vp.ToImage(Point{X: i * s}).X
+		fill(c, image.Rect(x, bounds.Min.Y, x+1, bounds.Max.Y), GridColor, draw.Over)
+	}
+
+	for i := math.Ceil(from.Y / s); i*s < to.Y; i++ {
+		y := vp.ToImage(Point{Y: i * s}).Y
+		fill(c, image.Rect(bounds.Min.X, y, bounds.Max.X, y+1), GridColor, draw.Over)
+	}
+}
+
+// snapShape puts the new shape on the grid of the store, which has to be
+// locked by the caller.
+func (gn *Generator) snapShape(sh Shape) {
+	g := gn.store.grid
+
+	if !g.Snap {
+		return
+	}
+
+	switch sh := sh.(type) {
+	case *TFigure:
+		sh.Center = g.SnapPoint(sh.Center)
+	case *BRect:
+		sh.Rect = Rectangle{Min: g.SnapPoint(sh.Rect.Min), Max: g.SnapPoint(sh.Rect.Max)}
+	}
+}
+
+// Grid returns the grid of the canvas.
+func (gn *Generator) Grid() Grid {
+	defer gn.store.shapesM.Unlock()
+
+	gn.store.shapesM.Lock()
+
+	return gn.store.grid
+}
+
+// SetGrid shows the grid with the Spacing, or turns it on and off when the
+// Spacing is not given.
+type SetGrid struct {
+	Spacing float64
+}
+
+func (sg SetGrid) String() string {
+	if sg.Spacing == 0 {
+		return "grid"
+	}
+
+	return "grid " + formatFloat(sg.Spacing)
+}
+
+func (sg SetGrid) MarshalText() ([]byte, error) {
+	return []byte(sg.String()), nil
+}
+
+func NewSetGrid(spacing float64) SetGrid {
+	return SetGrid{Spacing: spacing}
+}
+
+// ToggleSnap turns snapping to the grid on and off.
+type ToggleSnap struct{}
+
+func (ToggleSnap) String() string {
+	return "snap"
+}
+
+func (ts ToggleSnap) MarshalText() ([]byte, error) {
+	return []byte(ts.String()), nil
+}
@@ -0,0 +1,207 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// HandleColor fills the handles of the selection.
+var HandleColor = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+const (
+	// Handles are drawn gripSize pixels wide and can be grabbed a little
+	// further than that.
+	gripSize  = 8
+	gripReach = 6
+
+	// The rotation handle stands this many pixels above the selection.
+	rotateGripOffset = 20
+
+	// Shift snaps rotations to multiples of rotateSnap degrees.
+	rotateSnap = 15
+
+	// Resizing does not shrink the selection below this fraction of its
+	// size, which also keeps it from turning inside out.
+	minGripScale = 0.05
+)
+
+// gripKind tells what the left button holds by a handle of the selection.
+type gripKind int
+
+const (
+	noGrip gripKind = iota
+	resizeGrip
+	rotateGrip
+)
+
+// gripState is what a grabbed handle keeps from the press until release.
+type gripState struct {
+	kind gripKind
+
+	// anchor stays in place while the selection is resized, turns go
+	// around it.
+	anchor Point
+	from   Point
+
+	// scale and degrees are already posted during the drag.
+	scale   Point
+	degrees float64
+}
+
+// selectionBounds returns the rectangle around the selected shapes.
+func (cl *ClickHandler) selectionBounds() (Rectangle, bool) {
+	selection := cl.Selection()
+
+	if len(selection) == 0 {
+		return Rectangle{}, false
+	}
+
+	return boundsOf(selection), true
+}
+
+// grips returns the corners of the rectangle the handles are drawn at and
+// the position of the rotation handle.
+func grips(bounds Rectangle, vp Viewport) ([]Point, Point) {
+	px := vp.PixelSize()
+	corners := bounds.Grow(outlineMargin * px).Polygon()
+
+	rotation := Point{X: (bounds.Min.X + bounds.Max.X) / 2, Y: corners[0].Y - rotateGripOffset*px}
+
+	return corners, rotation
+}
+
+func nearPixel(vp Viewport, p Point, sp image.Point) bool {
+	d := vp.ToImage(p).Sub(sp)
+
+	return d.X*d.X+d.Y*d.Y <= gripReach*gripReach
+}
+
+// grab takes the handle under the cursor and tells whether there is one.
+func (cl *ClickHandler) grab(sp image.Point) bool {
+	bounds, ok := cl.selectionBounds()
+
+	if !ok {
+		return false
+	}
+
+	vp := cl.GetViewport()
+	corners, rotation := grips(bounds, vp)
+	from := vp.ToCanvas(sp)
+
+	if nearPixel(vp, rotation, sp) {
+		cl.grip = gripState{
+			kind:   rotateGrip,
+			anchor: Point{X: (bounds.Min.X + bounds.Max.X) / 2, Y: (bounds.Min.Y + bounds.Max.Y) / 2},
+			from:   from,
+		}
+		cl.startGesture()
+		return true
+	}
+
+	for i, corner := range corners {
+		if nearPixel(vp, corner, sp) {
+			cl.grip = gripState{
+				kind:   resizeGrip,
+				anchor: bounds.Polygon()[(i+2)%len(corners)],
+				from:   from,
+				scale:  Point{X: 1, Y: 1},
+			}
+			cl.startGesture()
+			return true
+		}
+	}
+
+	return false
+}
+
+// pull resizes or turns the selection following the handle to dest. With
+// Shift the proportions are kept and the turns snap to the steps.
+func (cl *ClickHandler) pull(dest image.Point, shift bool) {
+	cl.dropMissing()
+
+	if len(cl.selection) == 0 {
+		cl.grip = gripState{}
+		return
+	}
+
+	g := &cl.grip
+	p := cl.GetViewport().ToCanvas(dest)
+
+	switch g.kind {
+	case resizeGrip:
+		scale := Point{X: gripScale(g.anchor.X, g.from.X, p.X), Y: gripScale(g.anchor.Y, g.from.Y, p.Y)}
+
+		if shift {
+			s := math.Max(scale.X, scale.Y)
+			scale = Point{X: s, Y: s}
+		}
+
+		if scale == g.scale {
+			return
+		}
+
+		step := Point{X: scale.X / g.scale.X, Y: scale.Y / g.scale.Y}
+		anchor := g.anchor
+		g.scale = scale
+
+		for _, id := range cl.representatives() {
+			cl.postGesture(NewScale(id, step.X, step.Y, &anchor))
+		}
+
+	case rotateGrip:
+		degrees := angle(g.anchor, p) - angle(g.anchor, g.from)
+
+		if shift {
+			degrees = math.Round(degrees/rotateSnap) * rotateSnap
+		}
+
+		// turns are posted by the shortest way
+		step := math.Remainder(degrees-g.degrees, 360)
+
+		if step == 0 {
+			return
+		}
+
+		g.degrees += step
+		anchor := g.anchor
+
+		for _, id := range cl.representatives() {
+			cl.postGesture(NewRotate(id, step, &anchor))
+		}
+	}
+}
+
+// gripScale returns how much the side from the anchor to from has to be
+// stretched to reach to.
+func gripScale(anchor, from, to float64) float64 {
+	if from == anchor {
+		return 1
+	}
+
+	return math.Max((to-anchor)/(from-anchor), minGripScale)
+}
+
+// angle returns the direction from p to q in degrees clockwise on the
+// screen.
+func angle(p, q Point) float64 {
+	return math.Atan2(q.Y-p.Y, q.X-p.X) * 180 / math.Pi
+}
+
+// drawGrips draws the handles around the bounds of the selection.
+func drawGrips(c Canvas, vp Viewport, bounds Rectangle) {
+	px := vp.PixelSize()
+	corners, rotation := grips(bounds, vp)
+	outline := Stroke{Color: SelectionColor, Width: px}
+
+	outline.Draw(c, vp, []Point{{X: rotation.X, Y: corners[0].Y}, rotation}, false)
+
+	for _, corner := range append(corners, rotation) {
+		square := Rectangle{Min: corner, Max: corner}.Grow(gripSize / 2 * px).Polygon()
+
+		fillPolygons(c, vp, [][]Point{square}, HandleColor, screen.Src)
+		outline.Draw(c, vp, square, true)
+	}
+}
@@ -0,0 +1,344 @@
+package painter
+
+import (
+	"log"
+	"strconv"
+)
+
+// group holds shapes and other groups, commands aimed at any of them
+// apply to the whole topmost group.
+type group struct {
+	name   string
+	parent *group
+	shapes []int
+	groups []*group
+}
+
+// transformable is implemented by shapes able to take any affine transform,
+// others only follow it with their centers.
+type transformable interface {
+	Apply(m Affine)
+}
+
+func applyAffine(sh Shape, m Affine) {
+	if t, ok := sh.(transformable); ok {
+		t.Apply(m)
+		return
+	}
+
+	bounds := sh.Bounds()
+	center := Point{X: (bounds.Min.X + bounds.Max.X) / 2, Y: (bounds.Min.Y + bounds.Max.Y) / 2}
+	moved := m.Apply(center)
+
+	sh.Move(Point{X: moved.X - center.X, Y: moved.Y - center.Y})
+}
+
+func (ln *Line) Apply(m Affine) {
+	ln.From = m.Apply(ln.From)
+	ln.To = m.Apply(ln.To)
+}
+
+// topGroup returns the outermost group of the shape with the id, or nil
+// when it is not grouped. The store has to be locked by the caller.
+func (gn *Generator) topGroup(id int) *group {
+	g := gn.store.shapeGroups[id]
+
+	for g != nil && g.parent != nil {
+		g = g.parent
+	}
+
+	return g
+}
+
+// members returns the shapes of the group and of the groups inside of it,
+// the store has to be locked by the caller.
+func (gn *Generator) members(g *group) (shapes []Shape) {
+	for _, id := range g.shapes {
+		if l, i, ok := gn.locateShape(id); ok {
+			shapes = append(shapes, l.shapes[i])
+		}
+	}
+
+	for _, sub := range g.groups {
+		shapes = append(shapes, gn.members(sub)...)
+	}
+
+	return
+}
+
+// targets returns the shapes a command aimed at the shape with the id
+// applies to: the whole group of the shape or the shape alone. The store
+// has to be locked by the caller.
+func (gn *Generator) targets(id int) []Shape {
+	if g := gn.topGroup(id); g != nil {
+		return gn.members(g)
+	}
+
+	if sh, ok := gn.findShape(id); ok {
+		return []Shape{sh}
+	}
+
+	return nil
+}
+
+// GroupShapes returns copies of the shapes moved together with sh.
+func (gn *Generator) GroupShapes(sh Shape) (shapes []Shape) {
+	defer gn.store.shapesM.Unlock()
+
+	gn.store.shapesM.Lock()
+
+	g := gn.topGroup(sh.GetID())
+
+	if g == nil {
+		return []Shape{sh.clone()}
+	}
+
+	for _, member := range gn.members(g) {
+		shapes = append(shapes, member.clone())
+	}
+
+	return
+}
+
+// transformTargets applies the transform made around the point to the
+// shapes the command aimed at the id applies to. A single figure turns
+// around its center and a group around the center of its bounds, unless
+// the point is given. The store has to be locked by the caller.
+func (gn *Generator) transformTargets(id int, around *Point, transform func(p Point) Affine) {
+	shapes := gn.targets(id)
+
+	if len(shapes) == 0 {
+		return
+	}
+
+	if len(shapes) == 1 {
+		if _, ok := shapes[0].(transformable); !ok {
+			log.Printf("shape with id %d can not be transformed", id)
+			return
+		}
+	}
+
+	var p Point
+
+	switch tf, ok := shapes[0].(*TFigure); {
+	case around != nil:
+		p = *around
+	case len(shapes) == 1 && ok:
+		p = tf.Center
+	default:
+		bounds := shapes[0].Bounds()
+		for _, sh := range shapes[1:] {
+			bounds = bounds.Union(sh.Bounds())
+		}
+		p = Point{X: (bounds.Min.X + bounds.Max.X) / 2, Y: (bounds.Min.Y + bounds.Max.Y) / 2}
+	}
+
+	m := transform(p)
+
+	for _, sh := range shapes {
+		applyAffine(sh, m)
+	}
+}
+
+// addGroup puts the members into the group with the name, creating it when
+// there is none. The store has to be locked by the caller.
+func (gn *Generator) addGroup(op Group) {
+	if gn.store.groups == nil {
+		gn.store.groups = map[string]*group{}
+		gn.store.shapeGroups = map[int]*group{}
+	}
+
+	g, ok := gn.store.groups[op.Name]
+
+	if !ok {
+		g = &group{name: op.Name}
+		gn.store.groups[op.Name] = g
+	}
+
+	for _, id := range op.IDs {
+		if _, ok := gn.findShape(id); !ok {
+			continue
+		}
+
+		if old := gn.store.shapeGroups[id]; old != nil {
+			old.shapes = removeID(old.shapes, id)
+		}
+
+		g.shapes = append(g.shapes, id)
+		gn.store.shapeGroups[id] = g
+	}
+
+	for _, name := range op.Groups {
+		sub, ok := gn.store.groups[name]
+
+		if !ok {
+			log.Printf("no group named %s", name)
+			continue
+		}
+
+		// a group can not end up inside of itself
+		cycle := false
+		for up := g; up != nil; up = up.parent {
+			cycle = cycle || up == sub
+		}
+
+		if cycle {
+			log.Printf("group %s can not be put into %s", name, op.Name)
+			continue
+		}
+
+		if sub.parent != nil {
+			sub.parent.groups = removeGroup(sub.parent.groups, sub)
+		}
+
+		sub.parent = g
+		g.groups = append(g.groups, sub)
+	}
+}
+
+// ungroup dissolves the group with the name, its members go to the group
+// around it. The store has to be locked by the caller.
+func (gn *Generator) ungroup(name string) {
+	g, ok := gn.store.groups[name]
+
+	if !ok {
+		log.Printf("no group named %s", name)
+		return
+	}
+
+	for _, id := range g.shapes {
+		gn.store.shapeGroups[id] = g.parent
+
+		if g.parent == nil {
+			delete(gn.store.shapeGroups, id)
+		}
+	}
+
+	for _, sub := range g.groups {
+		sub.parent = g.parent
+	}
+
+	if g.parent != nil {
+		g.parent.shapes = append(g.parent.shapes, g.shapes...)
+		g.parent.groups = append(removeGroup(g.parent.groups, g), g.groups...)
+	}
+
+	delete(gn.store.groups, name)
+}
+
+// deleteShapes removes the shape with the id together with its group, the
+// store has to be locked by the caller.
+func (gn *Generator) deleteShapes(id int) {
+	shapes := gn.targets(id)
+
+	if g := gn.topGroup(id); g != nil {
+		gn.forgetGroup(g)
+	}
+
+	for _, sh := range shapes {
+		if l, i, ok := gn.locateShape(sh.GetID()); ok {
+			l.shapes = append(l.shapes[:i], l.shapes[i+1:]...)
+		}
+
+		if sh == Shape(gn.store.brect) {
+			gn.store.brect = nil
+		}
+	}
+}
+
+// forgetGroup drops the group and the groups inside of it, leaving the
+// shapes in place. The store has to be locked by the caller.
+func (gn *Generator) forgetGroup(g *group) {
+	for _, sub := range g.groups {
+		gn.forgetGroup(sub)
+	}
+
+	for _, id := range g.shapes {
+		delete(gn.store.shapeGroups, id)
+	}
+
+	delete(gn.store.groups, g.name)
+}
+
+func removeID(ids []int, id int) []int {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+
+	return ids
+}
+
+func removeGroup(groups []*group, g *group) []*group {
+	for i, v := range groups {
+		if v == g {
+			return append(groups[:i], groups[i+1:]...)
+		}
+	}
+
+	return groups
+}
+
+// Group puts the shapes with the IDs and the groups with the names into the
+// group with the Name, so they are moved, transformed and deleted together.
+type Group struct {
+	Name   string
+	IDs    []int
+	Groups []string
+}
+
+func (g Group) String() string {
+	s := "group " + g.Name
+
+	for _, id := range g.IDs {
+		s += " " + strconv.Itoa(id)
+	}
+
+	for _, name := range g.Groups {
+		s += " " + name
+	}
+
+	return s
+}
+
+func (g Group) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+func NewGroup(name string, ids []int, groups []string) Group {
+	return Group{Name: name, IDs: ids, Groups: groups}
+}
+
+type Ungroup struct {
+	Name string
+}
+
+func (ug Ungroup) String() string {
+	return "ungroup " + ug.Name
+}
+
+func (ug Ungroup) MarshalText() ([]byte, error) {
+	return []byte(ug.String()), nil
+}
+
+func NewUngroup(name string) Ungroup {
+	return Ungroup{Name: name}
+}
+
+// Delete removes the shape with the ID, or the whole group it belongs to.
+type Delete struct {
+	ID int
+}
+
+func (d Delete) String() string {
+	return "delete " + strconv.Itoa(d.ID)
+}
+
+func (d Delete) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func NewDelete(id int) Delete {
+	return Delete{ID: id}
+}
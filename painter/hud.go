@@ -0,0 +1,165 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"time"
+)
+
+var (
+	// HUDColor is premultiplied by its alpha.
+	HUDColor     = color.RGBA{R: 0x10, G: 0x10, B: 0x10, A: 0xb0}
+	HUDTextColor = color.RGBA{R: 0xa0, G: 0xff, B: 0xa0, A: 0xff}
+)
+
+// Sizes of the HUD in pixels.
+const (
+	hudLine    = 18
+	hudText    = 13
+	hudPadding = 6
+)
+
+// hudSelected is the number of selected shapes described by the HUD, the
+// rest are only counted.
+const hudSelected = 3
+
+// HUD shows how the painter is doing in the top-left corner of the window:
+// frames per second, the time of the last frame, operations waiting in the
+// loop, shapes in the scene, the cursor and the selected shapes.
+type HUD struct {
+	m sync.Mutex
+
+	// frames holds the starts of the frames of the last second.
+	frames    []time.Time
+	frameTime time.Duration
+
+	// idle is set once the HUD has shown that no frames were drawn during
+	// the last second.
+	idle bool
+
+	cursor image.Point
+
+	GetQueueLength func() int
+	GetShapes      func() []Shape
+
+	Selection *ClickHandler
+}
+
+// Frame counts the frame started at the time and taking d to draw.
+func (h *HUD) Frame(start time.Time, d time.Duration) {
+	defer h.m.Unlock()
+
+	h.m.Lock()
+
+	i := 0
+	for i < len(h.frames) && start.Sub(h.frames[i]) >= time.Second {
+		i++
+	}
+
+	h.frames = append(h.frames[i:], start)
+	h.frameTime = d
+}
+
+// Stale tells whether the HUD still shows the rate of frames drawn more
+// than a second ago, so it has to be drawn again to show the painter is
+// idle.
+func (h *HUD) Stale() bool {
+	defer h.m.Unlock()
+
+	h.m.Lock()
+
+	return !h.idle && (len(h.frames) == 0 || time.Since(h.frames[len(h.frames)-1]) >= time.Second)
+}
+
+// Track follows the cursor in the window, it is shown in canvas
+// coordinates.
+func (h *HUD) Track(sp image.Point) {
+	defer h.m.Unlock()
+
+	h.m.Lock()
+
+	h.cursor = sp
+}
+
+// lines returns the text shown by the HUD.
+func (h *HUD) lines(vp Viewport) []string {
+	h.m.Lock()
+
+	// frames are only counted when they are drawn, so the rate is taken
+	// over the second before now and drops once the painter is idle
+	fps, now := 0, time.Now()
+	for _, start := range h.frames {
+		if now.Sub(start) < time.Second {
+			fps++
+		}
+	}
+
+	h.idle = fps == 0
+	frameTime, cursor := h.frameTime, vp.ToCanvas(h.cursor)
+
+	h.m.Unlock()
+
+	rate := fmt.Sprintf("fps %d", fps)
+	if fps == 0 {
+		rate = "idle"
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s, frame %.1fms", rate, float64(frameTime.Microseconds())/1000),
+	}
+
+	if h.GetQueueLength != nil {
+		lines = append(lines, fmt.Sprintf("queue %d", h.GetQueueLength()))
+	}
+
+	if h.GetShapes != nil {
+		lines = append(lines, fmt.Sprintf("elements %d", len(h.GetShapes())))
+	}
+
+	lines = append(lines, fmt.Sprintf("cursor %.3f %.3f", cursor.X, cursor.Y))
+
+	if h.Selection == nil {
+		return lines
+	}
+
+	selection := h.Selection.Selection()
+
+	for i, sh := range selection {
+		if i == hudSelected {
+			lines = append(lines, fmt.Sprintf("and %d more selected", len(selection)-i))
+			break
+		}
+
+		lines = append(lines, fmt.Sprintf("#%d %v", sh.GetID(), sh))
+	}
+
+	return lines
+}
+
+// Draw shows the lines of the HUD over a dark box, it stays in place when
+// the camera moves, so it is drawn in pixels.
+func (h *HUD) Draw(c Canvas, vp Viewport) {
+	px := NewViewport(image.Rect(0, 0, 1, 1))
+	bounds := c.Bounds()
+	at := Point{X: float64(bounds.Min.X + hudPadding), Y: float64(bounds.Min.Y + hudPadding)}
+
+	var texts []Text
+	box := Rectangle{Min: Point{X: float64(bounds.Min.X), Y: float64(bounds.Min.Y)}}
+	box.Max = box.Min
+
+	for _, line := range h.lines(vp) {
+		txt := NewText(at.X, at.Y, line, hudText, HUDTextColor)
+		texts = append(texts, txt)
+		box = box.Union(txt.Bounds().Grow(hudPadding))
+		at.Y += hudLine
+	}
+
+	fillPolygons(c, px, [][]Point{box.Polygon()}, HUDColor, draw.Over)
+
+	for _, txt := range texts {
+		txt.Draw(c, px)
+	}
+}
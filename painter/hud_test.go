@@ -0,0 +1,63 @@
+package painter
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestHUD(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewTFigure(0.2, 0.2))
+
+	cl := newTestClickHandler(&gen)
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	hud := HUD{GetQueueLength: func() int { return 7 }, GetShapes: gen.Snapshot, Selection: cl}
+
+	start := time.Now()
+	hud.Frame(start.Add(-2*time.Second), time.Millisecond)
+	hud.Frame(start.Add(-time.Second/2), time.Millisecond)
+	hud.Frame(start, 2500*time.Microsecond)
+	hud.Track(image.Pt(25, 75))
+
+	text := strings.Join(hud.lines(cl.GetViewport()), "\n")
+
+	for _, want := range []string{"fps 2", "frame 2.5ms", "queue 7", "elements 2", "cursor 0.250 0.750", "#1 figure 0.5 "} {
+		if !strings.Contains(text, want) {
+			t.Errorf("HUD has no `%s` in\n%s", want, text)
+		}
+	}
+
+	if hud.Stale() {
+		t.Errorf("HUD showing the latest frame is stale")
+	}
+
+	// once no frame is drawn for a second the HUD shows it
+	idle := HUD{}
+	idle.Frame(start.Add(-1500*time.Millisecond), time.Millisecond)
+
+	if !idle.Stale() {
+		t.Errorf("HUD showing a frame drawn long ago has to be stale")
+	}
+
+	if line := idle.lines(cl.GetViewport())[0]; !strings.HasPrefix(line, "idle") {
+		t.Errorf("HUD shows `%s` without frames, expected idle", line)
+	}
+
+	if idle.Stale() {
+		t.Errorf("HUD showing idle has to stay until a frame is drawn")
+	}
+
+	img := NewImageCanvas(image.Pt(100, 100))
+	hud.Draw(img, gen.Viewport(image.Pt(100, 100)))
+
+	if got := img.RGBAAt(2, 2); got != HUDColor {
+		t.Errorf("HUD box is %v, expected %v", got, HUDColor)
+	}
+}
@@ -0,0 +1,318 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// Chord is a key pressed together with the modifiers.
+type Chord struct {
+	Code      key.Code
+	Modifiers key.Modifiers
+}
+
+// chordModifiers are the modifiers telling chords apart, others are dropped.
+const chordModifiers = key.ModShift | key.ModControl | key.ModAlt | key.ModMeta
+
+var modifierNames = []struct {
+	mod  key.Modifiers
+	name string
+}{
+	{key.ModControl, "Ctrl"},
+	{key.ModAlt, "Alt"},
+	{key.ModMeta, "Meta"},
+	{key.ModShift, "Shift"},
+}
+
+var keyNames = map[string]key.Code{
+	"Enter":     key.CodeReturnEnter,
+	"Escape":    key.CodeEscape,
+	"Backspace": key.CodeDeleteBackspace,
+	"Tab":       key.CodeTab,
+	"Space":     key.CodeSpacebar,
+	"Delete":    key.CodeDeleteForward,
+	"Insert":    key.CodeInsert,
+	"Home":      key.CodeHome,
+	"End":       key.CodeEnd,
+	"PageUp":    key.CodePageUp,
+	"PageDown":  key.CodePageDown,
+	"Left":      key.CodeLeftArrow,
+	"Right":     key.CodeRightArrow,
+	"Up":        key.CodeUpArrow,
+	"Down":      key.CodeDownArrow,
+	"Minus":     key.CodeHyphenMinus,
+	"Equal":     key.CodeEqualSign,
+	"Slash":     key.CodeSlash,
+	"Comma":     key.CodeComma,
+	"Period":    key.CodeFullStop,
+}
+
+func init() {
+	for i := 0; i < 26; i++ {
+		keyNames[string(rune('A'+i))] = key.CodeA + key.Code(i)
+	}
+
+	for i := 1; i <= 9; i++ {
+		keyNames[fmt.Sprint(i)] = key.Code1 + key.Code(i-1)
+	}
+	keyNames["0"] = key.Code0
+
+	for i := 1; i <= 12; i++ {
+		keyNames[fmt.Sprintf("F%d", i)] = key.CodeF1 + key.Code(i-1)
+	}
+}
+
+// ChordOf returns the chord of the key event.
+func ChordOf(e key.Event) Chord {
+	return Chord{Code: e.Code, Modifiers: e.Modifiers & chordModifiers}
+}
+
+func (ch Chord) String() string {
+	s := ""
+
+	for _, m := range modifierNames {
+		if ch.Modifiers&m.mod != 0 {
+			s += m.name + "+"
+		}
+	}
+
+	for name, code := range keyNames {
+		if code == ch.Code {
+			return s + name
+		}
+	}
+
+	return s + fmt.Sprint(ch.Code)
+}
+
+// ParseChord reads a chord written as modifiers and a key joined with `+`,
+// like `Ctrl+Shift+Z` or `Delete`.
+func ParseChord(s string) (Chord, error) {
+	parts := strings.Split(s, "+")
+	ch := Chord{}
+
+	for _, part := range parts[:len(parts)-1] {
+		found := false
+
+		for _, m := range modifierNames {
+			if strings.EqualFold(part, m.name) {
+				ch.Modifiers |= m.mod
+				found = true
+			}
+		}
+
+		if !found {
+			return Chord{}, fmt.Errorf("chord `%s`: no modifier named `%s`", s, part)
+		}
+	}
+
+	name := parts[len(parts)-1]
+
+	for n, code := range keyNames {
+		if strings.EqualFold(n, name) {
+			ch.Code = code
+			return ch, nil
+		}
+	}
+
+	return Chord{}, fmt.Errorf("chord `%s`: no key named `%s`", s, name)
+}
+
+// Actions done by the key bindings.
+const (
+	ActionDelete     = "delete"
+	ActionDuplicate  = "duplicate"
+	ActionCopy       = "copy"
+	ActionPaste      = "paste"
+	ActionNudgeLeft  = "nudge-left"
+	ActionNudgeRight = "nudge-right"
+	ActionNudgeUp    = "nudge-up"
+	ActionNudgeDown  = "nudge-down"
+	ActionJumpLeft   = "jump-left"
+	ActionJumpRight  = "jump-right"
+	ActionJumpUp     = "jump-up"
+	ActionJumpDown   = "jump-down"
+	ActionUndo       = "undo"
+	ActionReset      = "reset"
+	ActionPalette    = "palette"
+	ActionToggleGrid = "toggle-grid"
+	ActionToggleSnap = "toggle-snap"
+)
+
+// Bindings tell the action done by every chord.
+type Bindings map[Chord]string
+
+// DefaultBindings returns the bindings the painter starts with.
+func DefaultBindings() Bindings {
+	return Bindings{
+		{Code: key.CodeDeleteForward}:                       ActionDelete,
+		{Code: key.CodeDeleteBackspace}:                     ActionDelete,
+		{Code: key.CodeD, Modifiers: key.ModControl}:        ActionDuplicate,
+		{Code: key.CodeC, Modifiers: key.ModControl}:        ActionCopy,
+		{Code: key.CodeV, Modifiers: key.ModControl}:        ActionPaste,
+		{Code: key.CodeLeftArrow}:                           ActionNudgeLeft,
+		{Code: key.CodeRightArrow}:                          ActionNudgeRight,
+		{Code: key.CodeUpArrow}:                             ActionNudgeUp,
+		{Code: key.CodeDownArrow}:                           ActionNudgeDown,
+		{Code: key.CodeLeftArrow, Modifiers: key.ModShift}:  ActionJumpLeft,
+		{Code: key.CodeRightArrow, Modifiers: key.ModShift}: ActionJumpRight,
+		{Code: key.CodeUpArrow, Modifiers: key.ModShift}:    ActionJumpUp,
+		{Code: key.CodeDownArrow, Modifiers: key.ModShift}:  ActionJumpDown,
+		{Code: key.CodeZ, Modifiers: key.ModControl}:        ActionUndo,
+		{Code: key.CodeR, Modifiers: key.ModControl}:        ActionReset,
+		{Code: key.CodeSlash}:                               ActionPalette,
+		{Code: key.CodeP, Modifiers: key.ModControl}:        ActionPalette,
+		{Code: key.CodeG}:                                   ActionToggleGrid,
+		{Code: key.CodeG, Modifiers: key.ModShift}:          ActionToggleSnap,
+	}
+}
+
+// Parse adds the bindings written as comma separated `chord=action` pairs,
+// like `Ctrl+Y=undo,X=delete`, replacing the ones of the same chords.
+func (b Bindings) Parse(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		chord, action, ok := strings.Cut(strings.TrimSpace(pair), "=")
+
+		if !ok {
+			return fmt.Errorf("binding `%s` has to be written as chord=action", pair)
+		}
+
+		ch, err := ParseChord(chord)
+
+		if err != nil {
+			return err
+		}
+
+		if _, ok := shortcutActions[action]; !ok {
+			return fmt.Errorf("binding `%s`: no action named `%s`", pair, action)
+		}
+
+		b[ch] = action
+	}
+
+	return nil
+}
+
+// Shortcuts do the actions bound to the keys, while the palette is open it
+// gets the keys instead.
+type Shortcuts struct {
+	Bindings Bindings
+
+	Selection *ClickHandler
+	Palette   *Palette
+
+	// clipboard holds the ids of the shapes copied, every paste puts their
+	// copies further away from them.
+	clipboard []int
+	pasted    int
+
+	PostOperation func(op Operation)
+}
+
+// duplicateOffset is the distance in pixels between a shape and its copy,
+// or between pasted copies.
+const duplicateOffset = 10
+
+var shortcutActions = map[string]func(sc *Shortcuts) bool{
+	ActionDelete: func(sc *Shortcuts) bool {
+		return sc.eachSelected(func(id int) Operation { return NewDelete(id) })
+	},
+	ActionDuplicate: func(sc *Shortcuts) bool {
+		return sc.Selection != nil && sc.Selection.Duplicate(image.Pt(duplicateOffset, duplicateOffset))
+	},
+	ActionCopy: func(sc *Shortcuts) bool {
+		if sc.Selection != nil {
+			sc.clipboard = sc.Selection.Copy()
+			sc.pasted = 0
+		}
+		return false
+	},
+	ActionPaste: func(sc *Shortcuts) bool {
+		if sc.Selection == nil {
+			return false
+		}
+
+		sc.pasted++
+		d := duplicateOffset * sc.pasted
+		return sc.Selection.Paste(sc.clipboard, image.Pt(d, d))
+	},
+	ActionNudgeLeft:  func(sc *Shortcuts) bool { return sc.nudge(-nudgeStep, 0) },
+	ActionNudgeRight: func(sc *Shortcuts) bool { return sc.nudge(nudgeStep, 0) },
+	ActionNudgeUp:    func(sc *Shortcuts) bool { return sc.nudge(0, -nudgeStep) },
+	ActionNudgeDown:  func(sc *Shortcuts) bool { return sc.nudge(0, nudgeStep) },
+	ActionJumpLeft:   func(sc *Shortcuts) bool { return sc.nudge(-nudgeShiftStep, 0) },
+	ActionJumpRight:  func(sc *Shortcuts) bool { return sc.nudge(nudgeShiftStep, 0) },
+	ActionJumpUp:     func(sc *Shortcuts) bool { return sc.nudge(0, -nudgeShiftStep) },
+	ActionJumpDown:   func(sc *Shortcuts) bool { return sc.nudge(0, nudgeShiftStep) },
+	ActionUndo: func(sc *Shortcuts) bool {
+		sc.PostOperation(Undo{})
+		return false
+	},
+	ActionReset: func(sc *Shortcuts) bool {
+		sc.PostOperation(Reset{})
+		return false
+	},
+	ActionToggleGrid: func(sc *Shortcuts) bool {
+		sc.PostOperation(SetGrid{})
+		return false
+	},
+	ActionToggleSnap: func(sc *Shortcuts) bool {
+		sc.PostOperation(ToggleSnap{})
+		return false
+	},
+	ActionPalette: func(sc *Shortcuts) bool {
+		if sc.Palette == nil {
+			return false
+		}
+
+		sc.Palette.Open()
+		return true
+	},
+}
+
+func (sc *Shortcuts) nudge(dx, dy int) bool {
+	return sc.Selection != nil && sc.Selection.Nudge(image.Pt(dx, dy))
+}
+
+// eachSelected posts the operation made for every selected group and tells
+// whether there was any.
+func (sc *Shortcuts) eachSelected(op func(id int) Operation) bool {
+	if sc.Selection == nil {
+		return false
+	}
+
+	ids := sc.Selection.representatives()
+
+	for _, id := range ids {
+		sc.PostOperation(op(id))
+	}
+
+	return len(ids) != 0
+}
+
+// HandleKey does the action bound to the key and tells whether the window
+// has to be redrawn, keys without an action are left to the caller.
+func (sc *Shortcuts) HandleKey(e key.Event) bool {
+	if sc.Palette != nil && sc.Palette.IsOpen() {
+		return sc.Palette.HandleKey(e)
+	}
+
+	if e.Direction == key.DirRelease {
+		return false
+	}
+
+	action, ok := sc.Bindings[ChordOf(e)]
+
+	if !ok {
+		return false
+	}
+
+	return shortcutActions[action](sc)
+}
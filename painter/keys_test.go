@@ -0,0 +1,178 @@
+package painter
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestParseChord(t *testing.T) {
+	chords := map[string]Chord{
+		"Delete":       {Code: key.CodeDeleteForward},
+		"ctrl+shift+z": {Code: key.CodeZ, Modifiers: key.ModControl | key.ModShift},
+		"Alt+F4":       {Code: key.CodeF4, Modifiers: key.ModAlt},
+		"0":            {Code: key.Code0},
+	}
+
+	for s, expected := range chords {
+		ch, err := ParseChord(s)
+
+		if err != nil || ch != expected {
+			t.Errorf("`%s` gives %v, %v, expected %v", s, ch, err, expected)
+		}
+
+		if back, _ := ParseChord(ch.String()); back != ch {
+			t.Errorf("`%s` does not read back from `%s`", s, ch)
+		}
+	}
+
+	for _, s := range []string{"", "Ctrl+", "Hyper+A", "Ctrl+Banana"} {
+		if _, err := ParseChord(s); err == nil {
+			t.Errorf("`%s` has to be rejected", s)
+		}
+	}
+
+	b := DefaultBindings()
+
+	if err := b.Parse("Ctrl+Y=undo, X=delete"); err != nil {
+		t.Fatal(err)
+	}
+
+	if b[Chord{Code: key.CodeY, Modifiers: key.ModControl}] != ActionUndo || b[Chord{Code: key.CodeX}] != ActionDelete {
+		t.Errorf("bindings are not added")
+	}
+
+	for _, s := range []string{"X", "X=fly", "Ctrl+Banana=undo"} {
+		if err := b.Parse(s); err == nil {
+			t.Errorf("`%s` has to be rejected", s)
+		}
+	}
+}
+
+func TestShortcuts(t *testing.T) {
+	gen := Generator{}
+	gen.Update(NewTFigure(0.5, 0.5))
+	gen.Update(NewTFigure(0.2, 0.2))
+
+	cl := newTestClickHandler(&gen)
+	sc := Shortcuts{Bindings: DefaultBindings(), Selection: cl, PostOperation: gen.Update}
+
+	press := func(code key.Code, mods key.Modifiers) bool {
+		return sc.HandleKey(key.Event{Code: code, Modifiers: mods, Direction: key.DirPress})
+	}
+
+	if press(key.CodeDeleteForward, 0) {
+		t.Errorf("delete without a selection has to do nothing")
+	}
+
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 50, Y: 45, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	press(key.CodeD, key.ModControl)
+
+	tfs := gen.GetTFigures()
+
+	if len(tfs) != 3 || tfs[2].ID != 3 || !near(tfs[2].Center, Point{0.6, 0.6}) {
+		t.Fatalf("duplicate has to add a figure at %v, got %d figures", Point{0.6, 0.6}, len(tfs))
+	}
+
+	press(key.CodeDeleteForward, 0)
+
+	if tfs := gen.GetTFigures(); len(tfs) != 2 || tfs[0].ID != 2 {
+		t.Errorf("delete has to remove the selected figure")
+	}
+
+	cl.Update(mouse.Event{X: 60, Y: 55, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	cl.Update(mouse.Event{X: 60, Y: 55, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+
+	press(key.CodeC, key.ModControl)
+	press(key.CodeV, key.ModControl)
+	press(key.CodeV, key.ModControl)
+
+	tfs = gen.GetTFigures()
+
+	if len(tfs) != 4 || tfs[3].ID != 5 || !near(tfs[2].Center, Point{0.7, 0.7}) || !near(tfs[3].Center, Point{0.8, 0.8}) {
+		t.Errorf("every paste has to add a figure further away, got %d figures", len(tfs))
+	}
+
+	var posted []Operation
+
+	sc.PostOperation = func(op Operation) { posted = append(posted, op) }
+	press(key.CodeZ, key.ModControl)
+	press(key.CodeR, key.ModControl)
+
+	if len(posted) != 2 || posted[0] != (Undo{}) || posted[1] != (Reset{}) {
+		t.Errorf("undo and reset are posted as %v", posted)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	var posted []Operation
+
+	p := Palette{
+		Parse: func(command string) ([]Operation, error) {
+			if command != "fit" {
+				return nil, errors.New("no such command")
+			}
+			return []Operation{Fit{}}, nil
+		},
+		PostOperations: func(ops []Operation) { posted = append(posted, ops...) },
+	}
+
+	sc := Shortcuts{Bindings: DefaultBindings(), Palette: &p}
+
+	typeKey := func(code key.Code, r rune) {
+		sc.HandleKey(key.Event{Code: code, Rune: r, Direction: key.DirPress})
+	}
+
+	typeLine := func(line string) {
+		for _, r := range line {
+			typeKey(key.CodeUnknown, r)
+		}
+		typeKey(key.CodeReturnEnter, -1)
+	}
+
+	typeKey(key.CodeSlash, '/')
+
+	if !p.IsOpen() || len(p.input) != 0 {
+		t.Fatalf("slash has to open an empty palette")
+	}
+
+	typeLine("fly")
+
+	if !p.IsOpen() || p.message == "" || len(posted) != 0 {
+		t.Errorf("a wrong command has to keep the palette open with the mistake shown")
+	}
+
+	img := NewImageCanvas(image.Pt(200, 100))
+	p.Draw(img, NewViewport(img.Bounds()))
+
+	if got := img.RGBAAt(195, 95); got != PaletteColor {
+		t.Errorf("palette has to be drawn at the bottom, got %v", got)
+	}
+
+	for range "fly" {
+		typeKey(key.CodeDeleteBackspace, -1)
+	}
+	typeLine("fit")
+
+	if p.IsOpen() || len(posted) != 1 || posted[0] != (Fit{}) {
+		t.Errorf("fit has to be posted and close the palette, got %v", posted)
+	}
+
+	typeKey(key.CodeSlash, '/')
+	typeKey(key.CodeUpArrow, -1)
+
+	if string(p.input) != "fit" {
+		t.Errorf("up has to bring back the last command, got `%s`", string(p.input))
+	}
+
+	typeKey(key.CodeEscape, -1)
+
+	if p.IsOpen() {
+		t.Errorf("escape has to close the palette")
+	}
+}
@@ -0,0 +1,424 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/magicvegetable/architecture-lab-3/painter"
+	"golang.org/x/image/colornames"
+)
+
+// CoordinatePolicy tells the parser what to do with coordinates that lie
+// outside of the canvas.
+type CoordinatePolicy int
+
+const (
+	RejectOutOfCanvas CoordinatePolicy = iota
+	ClampToCanvas
+)
+
+// ArgSpec declares a numeric argument of a command and the closed range
+// its value has to lie in.
+type ArgSpec struct {
+	Name     string
+	Min, Max float64
+
+	// Coordinate arguments are handled according to the CoordinatePolicy,
+	// any other argument out of its range is always rejected.
+	Coordinate bool
+
+	// Optional arguments close the list and are given all together or none.
+	Optional bool
+
+	// Integer arguments do not accept a fractional part.
+	Integer bool
+}
+
+func canvasCoordinate(name string) ArgSpec {
+	return ArgSpec{Name: name, Min: 0, Max: 1, Coordinate: true}
+}
+
+func canvasOffset(name string) ArgSpec {
+	return ArgSpec{Name: name, Min: -painter.MaxOffset, Max: painter.MaxOffset, Coordinate: true}
+}
+
+// pivot is a point things turn or stretch around, it may lie off the canvas
+// up to a canvas away from it.
+func pivot(name string) ArgSpec {
+	return ArgSpec{Name: name, Min: -painter.MaxOffset, Max: 1 + painter.MaxOffset}
+}
+
+func elementID() ArgSpec {
+	return ArgSpec{Name: "id", Min: 1, Max: math.MaxInt32, Integer: true}
+}
+
+func factor(name string) ArgSpec {
+	return ArgSpec{Name: name, Min: -painter.MaxFactor, Max: painter.MaxFactor}
+}
+
+func figureSize(name string) ArgSpec {
+	return ArgSpec{Name: name, Min: 0.001, Max: 1}
+}
+
+func strokeWidth(min float64) ArgSpec {
+	return ArgSpec{Name: "width", Min: min, Max: 0.25}
+}
+
+func optional(spec ArgSpec) ArgSpec {
+	spec.Optional = true
+	return spec
+}
+
+// ArgSpecs holds the numeric arguments of every command of the table,
+// gradients declare them for every kind and moves for a single shape
+// separately.
+var ArgSpecs = map[string][]ArgSpec{
+	"white": {},
+	"green": {},
+	"fill":  {},
+	"figure": {
+		canvasCoordinate("x"), canvasCoordinate("y"),
+		optional(figureSize("w")), optional(figureSize("h")),
+	},
+	"update": {},
+	"brect": {
+		canvasCoordinate("x1"), canvasCoordinate("y1"),
+		canvasCoordinate("x2"), canvasCoordinate("y2"),
+	},
+	"move": {
+		canvasOffset("dx"), canvasOffset("dy"),
+	},
+	"move id": {
+		elementID(), canvasOffset("dx"), canvasOffset("dy"),
+	},
+	"moveto": {
+		canvasCoordinate("x"), canvasCoordinate("y"),
+	},
+	"moveto id": {
+		elementID(), canvasCoordinate("x"), canvasCoordinate("y"),
+	},
+	"reset": {},
+	"zoom": {
+		{Name: "factor", Min: painter.MinZoom / painter.MaxZoom, Max: painter.MaxZoom / painter.MinZoom},
+		optional(canvasCoordinate("x")), optional(canvasCoordinate("y")),
+	},
+	"pan": {
+		canvasOffset("dx"), canvasOffset("dy"),
+	},
+	"fit":  {},
+	"undo": {},
+	"rotate": {
+		elementID(),
+		{Name: "degrees", Min: -painter.MaxDegrees, Max: painter.MaxDegrees},
+		optional(pivot("cx")), optional(pivot("cy")),
+	},
+	"scale": {
+		elementID(), factor("sx"), factor("sy"),
+		optional(pivot("cx")), optional(pivot("cy")),
+	},
+	"transform": {
+		elementID(),
+		factor("a"), factor("b"), factor("c"), factor("d"),
+		canvasOffset("e"), canvasOffset("f"),
+	},
+	"resize": {
+		elementID(), figureSize("w"), figureSize("h"),
+	},
+	"text": {
+		canvasCoordinate("x"), canvasCoordinate("y"),
+		optional(ArgSpec{Name: "size", Min: 0.005, Max: 0.5}),
+	},
+	"image": {
+		canvasCoordinate("x"), canvasCoordinate("y"),
+		figureSize("w"), figureSize("h"),
+	},
+	"stroke": {
+		elementID(), strokeWidth(0),
+	},
+	"line": {
+		canvasCoordinate("x1"), canvasCoordinate("y1"),
+		canvasCoordinate("x2"), canvasCoordinate("y2"),
+		strokeWidth(0.0005),
+	},
+	"layer": {
+		optional(ArgSpec{Name: "z", Min: -1000, Max: 1000, Integer: true}),
+	},
+	"show": {},
+	"hide": {},
+	"opacity": {
+		{Name: "opacity", Min: 0, Max: 1},
+	},
+	"bring-to-front": {
+		elementID(),
+	},
+	"send-to-back": {
+		elementID(),
+	},
+	"group":   {},
+	"ungroup": {},
+	"delete": {
+		elementID(),
+	},
+	"grid": {
+		optional(ArgSpec{Name: "spacing", Min: 0.005, Max: 0.5}),
+	},
+	"snap": {},
+	"clone": {
+		elementID(), optional(canvasOffset("dx")), optional(canvasOffset("dy")),
+	},
+	"align":      {},
+	"distribute": {},
+	"gradient linear": {
+		canvasCoordinate("x1"), canvasCoordinate("y1"),
+		canvasCoordinate("x2"), canvasCoordinate("y2"),
+	},
+	"gradient radial": {
+		canvasCoordinate("cx"), canvasCoordinate("cy"),
+		{Name: "r", Min: 0.001, Max: 2},
+	},
+}
+
+func (spec ArgSpec) parse(arg string, policy CoordinatePolicy) (float64, error) {
+	v, err := strconv.ParseFloat(arg, 64)
+
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("argument %s = `%s` is not a number", spec.Name, arg)
+	}
+
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("argument %s = `%s` is not a finite number", spec.Name, arg)
+	}
+
+	if spec.Integer && v != math.Trunc(v) {
+		return 0, fmt.Errorf("argument %s = %s is not an integer", spec.Name, arg)
+	}
+
+	if spec.Min <= v && v <= spec.Max {
+		return v, nil
+	}
+
+	if spec.Coordinate && policy == ClampToCanvas {
+		return math.Min(math.Max(v, spec.Min), spec.Max), nil
+	}
+
+	return 0, fmt.Errorf(
+		"argument %s = %s is out of range [%s, %s]",
+		spec.Name, arg, strconv.FormatFloat(spec.Min, 'g', -1, 64), strconv.FormatFloat(spec.Max, 'g', -1, 64),
+	)
+}
+
+func (p *Parser) parseArgs(command string, args []string) ([]float64, error) {
+	specs := ArgSpecs[command]
+
+	required := 0
+	for required < len(specs) && !specs[required].Optional {
+		required++
+	}
+
+	if lenArgs := len(args); lenArgs != required && lenArgs != len(specs) {
+		amount := fmt.Sprint(len(specs))
+		if required != len(specs) {
+			amount = fmt.Sprintf("%d or %d", required, len(specs))
+		}
+
+		errMessage := fmt.Sprintf(
+			"wrong len(%d) of args for operation `%s`, amount have to be %s",
+			lenArgs, command, amount,
+		)
+
+		return nil, errors.New(errMessage)
+	}
+
+	values := make([]float64, len(args))
+
+	for i, spec := range specs[:len(args)] {
+		v, err := spec.parse(args[i], p.Policy)
+
+		if err != nil {
+			return nil, fmt.Errorf("operation `%s`: %w", command, err)
+		}
+
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+// isColor tells whether the argument is meant to be a color: either a hex
+// `#rrggbb` or `#rrggbbaa` value or a name from the SVG palette.
+func isColor(arg string) bool {
+	_, named := colornames.Map[strings.ToLower(arg)]
+	return named || strings.HasPrefix(arg, "#")
+}
+
+func parseColor(arg string) (color.RGBA, error) {
+	if c, ok := colornames.Map[strings.ToLower(arg)]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(arg, "#")
+
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("color `%s` has to be #rrggbb, #rrggbbaa or a color name", arg)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("color `%s` has to be #rrggbb, #rrggbbaa or a color name", arg)
+	}
+
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// splitColor takes the trailing color argument off args, if there is one.
+func splitColor(args []string, def color.RGBA) ([]string, color.RGBA, error) {
+	if len(args) == 0 || !isColor(args[len(args)-1]) {
+		return args, def, nil
+	}
+
+	c, err := parseColor(args[len(args)-1])
+
+	return args[:len(args)-1], c, err
+}
+
+var dashLength = ArgSpec{Name: "dash", Min: 0.002, Max: 1}
+
+// splitDash takes the dash lengths, which follow the first count arguments,
+// off args.
+func (p *Parser) splitDash(args []string, count int) ([]string, []float64, error) {
+	if len(args) <= count {
+		return args, nil, nil
+	}
+
+	if dashes := len(args) - count; dashes > painter.MaxDashes {
+		errMessage := fmt.Sprintf("dash pattern has %d lengths, amount have to be at most %d", dashes, painter.MaxDashes)
+		return nil, nil, errors.New(errMessage)
+	}
+
+	dash := make([]float64, 0, len(args)-count)
+
+	for _, arg := range args[count:] {
+		v, err := dashLength.parse(arg, p.Policy)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dash = append(dash, v)
+	}
+
+	return args[:count], dash, nil
+}
+
+// splitMembers sorts the members of a group into the ids of shapes and
+// the names of groups.
+func (p *Parser) splitMembers(args []string) ([]int, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("group needs at least one member")
+	}
+
+	var ids []int
+	var groups []string
+
+	for _, arg := range args {
+		if painter.ValidName(arg) {
+			groups = append(groups, arg)
+			continue
+		}
+
+		v, err := elementID().parse(arg, p.Policy)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ids = append(ids, int(v))
+	}
+
+	return ids, groups, nil
+}
+
+// splitIDs reads the ids of the shapes, there have to be at least least of
+// them.
+func (p *Parser) splitIDs(args []string, least int) ([]int, error) {
+	if len(args) < least {
+		return nil, fmt.Errorf("needs the ids of at least %d shapes", least)
+	}
+
+	ids := make([]int, 0, len(args))
+
+	for _, arg := range args {
+		v, err := elementID().parse(arg, p.Policy)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, int(v))
+	}
+
+	return ids, nil
+}
+
+// splitGradient takes the optional id, the kind and the color stops off the
+// arguments of a gradient, the numbers of its geometry are left.
+func (p *Parser) splitGradient(args []string) (int, painter.Gradient, []string, error) {
+	id := 0
+	g := painter.Gradient{}
+
+	if len(args) > 0 {
+		if _, err := strconv.ParseFloat(args[0], 64); err == nil {
+			v, err := elementID().parse(args[0], p.Policy)
+
+			if err != nil {
+				return 0, g, nil, err
+			}
+
+			id, args = int(v), args[1:]
+		}
+	}
+
+	if len(args) == 0 {
+		return 0, g, nil, errors.New("gradient kind has to be `linear` or `radial`")
+	}
+
+	kind, ok := painter.ParseGradientKind(args[0])
+
+	if !ok {
+		return 0, g, nil, fmt.Errorf("gradient kind `%s` has to be `linear` or `radial`", args[0])
+	}
+
+	g.Kind = kind
+	args = args[1:]
+
+	first := len(args)
+	for first > 0 && isColor(args[first-1]) {
+		first--
+	}
+
+	if stops := len(args) - first; stops < 2 || stops > painter.MaxGradientStops {
+		errMessage := fmt.Sprintf("gradient has %d color stops, amount have to be from 2 to %d", stops, painter.MaxGradientStops)
+		return 0, g, nil, errors.New(errMessage)
+	}
+
+	for _, arg := range args[first:] {
+		c, err := parseColor(arg)
+
+		if err != nil {
+			return 0, g, nil, err
+		}
+
+		g.Stops = append(g.Stops, c)
+	}
+
+	return id, g, args[:first], nil
+}
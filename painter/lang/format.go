@@ -0,0 +1,60 @@
+package lang
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/magicvegetable/architecture-lab-3/painter"
+)
+
+// Format rewrites the script read from in into its canonical form: one
+// command per line with normalized whitespace and numbers. Coordinates
+// outside of the canvas are handled by the policy of the parser.
+func (p *Parser) Format(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Split(bufio.ScanLines)
+
+	ops := []painter.Operation{}
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		for _, command := range splitCommands(scanner.Text()) {
+			op, err := p.GetOperation(command)
+
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			if op == nil {
+				continue
+			}
+
+			ops = append(ops, op)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return FormatOperations(out, ops)
+}
+
+// FormatOperations writes ops to out as a script, one command per line.
+func FormatOperations(out io.Writer, ops []painter.Operation) error {
+	w := bufio.NewWriter(out)
+
+	for _, op := range ops {
+		text, ok := op.(fmt.Stringer)
+
+		if !ok {
+			return fmt.Errorf("operation of type %T has no text form", op)
+		}
+
+		if _, err := fmt.Fprintln(w, text.String()); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,227 @@
+package lang
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/magicvegetable/architecture-lab-3/painter"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		result string
+	}{
+		{name: "empty", input: "\n\t\n", result: ""},
+		{name: "fills", input: "white  \n\tgreen", result: "white\ngreen\n"},
+		{name: "fill", input: "fill Navy & fill #ffffff", result: "fill #000080\nwhite\n"},
+		{name: "ampersand", input: "white & figure 0.50 .5&update", result: "white\nfigure 0.5 0.5\nupdate\n"},
+		{name: "numbers", input: "move +1e-1    -0.700", result: "move 0.1 -0.7\n"},
+		{name: "brect", input: "brect 0.75 0.75 0.25\t0.25", result: "brect 0.25 0.25 0.75 0.75\n"},
+		{name: "reset", input: "   reset   ", result: "reset\n"},
+		{name: "figure-size", input: "figure 0.5 0.5 0.10 0.2", result: "figure 0.5 0.5 0.1 0.2\n"},
+		{name: "figure-color", input: "figure 0.5 0.5 Navy", result: "figure 0.5 0.5 #000080\n"},
+		{name: "text", input: "text 0.1 0.2   \"a  & b\" & text 0.1 0.3 caption 0.1 red", result: "text 0.1 0.2 \"a  & b\"\ntext 0.1 0.3 \"caption\" 0.1 #ff0000\n"},
+		{name: "gradient", input: "gradient 3 radial .5 .5 1 White black", result: "gradient 3 radial 0.5 0.5 1 #ffffff #000000\n"},
+		{name: "stroke", input: "stroke 2 .01 .02 .01 Black", result: "stroke 2 0.01 0.02 0.01\n"},
+		{name: "figure-default", input: "figure 0.5 0.5 0.25 0.25 #FF6666", result: "figure 0.5 0.5\n"},
+	}
+
+	p := Parser{}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := p.Format(strings.NewReader(c.input), &out); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != c.result {
+				t.Errorf("got %q, expected %q", out.String(), c.result)
+			}
+		})
+	}
+
+	if err := p.Format(strings.NewReader("white\nsquare 1"), &bytes.Buffer{}); err == nil {
+		t.Errorf("expected an error for an unknown command")
+	}
+
+	if err := p.Format(strings.NewReader("move -3 0.25"), &bytes.Buffer{}); err == nil {
+		t.Errorf("expected an error for a move out of the canvas")
+	}
+
+	var out bytes.Buffer
+	clamping := Parser{Policy: ClampToCanvas}
+
+	if err := clamping.Format(strings.NewReader("move -3 0.25"), &out); err != nil {
+		t.Fatal(err)
+	} else if out.String() != "move -1 0.25\n" {
+		t.Errorf("got %q, expected the move clamped", out.String())
+	}
+}
+
+func TestOperationText(t *testing.T) {
+	minusOne := -1
+	ops := []painter.Operation{
+		painter.NewWhiteFill(),
+		painter.NewGreenFill(),
+		painter.NewFill(color.RGBA{R: 0x20, G: 0x40, B: 0x60, A: 0xff}),
+		painter.NewTFigure(0.1, 0.9),
+		painter.NewBRect(0.3, 0.5, 0.0, 0.2),
+		painter.NewMove(-0.25, 0.125),
+		painter.NewMoveShape(3, 0.5, -1),
+		painter.NewMoveTo(0.5, 1),
+		painter.NewMoveShapeTo(2, 0, 0.25),
+		painter.NewZoom(2, nil),
+		painter.NewZoom(0.5, &painter.Point{X: 0.25, Y: 0.75}),
+		painter.NewPan(0.1, -0.1),
+		painter.Fit{},
+		painter.Undo{},
+		painter.NewSetGrid(0.05),
+		painter.SetGrid{},
+		painter.ToggleSnap{},
+		painter.NewClone(2, 0, 0),
+		painter.NewClone(2, 0.1, -0.05),
+		painter.NewAlign(painter.AlignMiddle, []int{1, 3}),
+		painter.NewDistribute(painter.Vertical, []int{2, 1, 4}),
+		painter.NewRotate(2, -45, nil),
+		painter.NewRotate(1, 90, &painter.Point{X: 0.5, Y: 0.5}),
+		painter.NewScale(3, 2, -0.5, nil),
+		painter.NewScale(1, 1.5, 1.5, &painter.Point{X: -0.2, Y: 1.4}),
+		painter.NewTransform(1, painter.Affine{A: 1, B: 0.5, C: 0, D: 1, E: 0.1, F: 0}),
+		painter.NewCustomTFigure(0.5, 0.5, 0.1, 0.3, color.RGBA{R: 1, G: 2, B: 3, A: 4}),
+		painter.NewResize(1, 0.5, 0.05),
+		painter.NewText(0.5, 0.5, "quoted \"label\"\t& more", painter.TextSize, painter.TextColor),
+		painter.NewText(0, 1, "#fff", 0.1, color.RGBA{R: 0xff, A: 0xff}),
+		painter.NewPicture("logo.png", 0.1, 0.2, 0.3, 0.4),
+		painter.NewGradientFill(0, painter.Gradient{
+			Kind: painter.LinearGradient, From: painter.Point{X: 0, Y: 0}, To: painter.Point{X: 1, Y: 0.5},
+			Stops: []color.RGBA{{R: 0xff, A: 0xff}, {G: 0xff, A: 0xff}, {B: 0xff, A: 0x80}},
+		}),
+		painter.NewGradientFill(2, painter.Gradient{
+			Kind: painter.RadialGradient, From: painter.Point{X: 0.5, Y: 0.5}, Radius: 0.75,
+			Stops: []color.RGBA{{A: 0xff}, {R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+		}),
+		painter.NewLine(0.1, 0.9, 0.9, 0.1, 0.005, nil, painter.StrokeColor),
+		painter.NewLine(0, 0, 1, 1, 0.25, []float64{0.1, 0.05, 0.01}, color.RGBA{R: 0x10, A: 0xff}),
+		painter.NewSetStroke(1, 0.01, nil, color.RGBA{B: 0xff, A: 0xff}),
+		painter.NewSetStroke(2, 0, nil, painter.StrokeColor),
+		painter.NewSetStroke(3, 0.02, []float64{0.03, 0.01}, painter.StrokeColor),
+		painter.NewLayer("labels", nil),
+		painter.NewLayer("under-all_2", &minusOne),
+		painter.NewHideLayer("labels"),
+		painter.NewShowLayer("labels"),
+		painter.NewLayerOpacity("labels", 0.5),
+		painter.NewBringToFront(4),
+		painter.NewSendToBack(1),
+		painter.NewGroup("arms", []int{3}, nil),
+		painter.NewGroup("body", []int{1, 2}, []string{"arms", "legs"}),
+		painter.NewUngroup("body"),
+		painter.NewDelete(4),
+	}
+
+	var script bytes.Buffer
+
+	if err := FormatOperations(&script, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	p := Parser{}
+	res, err := p.ParseOperations(strings.NewReader(script.String() + "update"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ops, res) {
+		t.Errorf("round trip through %q gives %v, expected %v", script.String(), res, ops)
+	}
+}
+
+// frames counts the operations done by a loop.
+type frames chan struct{}
+
+func (f frames) Update() {
+	f <- struct{}{}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	gen := painter.Generator{}
+	done := make(frames)
+	l := painter.Loop{Gen: &gen, Receiver: done}
+	around := painter.Point{X: 0.5, Y: 0.5}
+
+	// drags adding up past the limits of their commands
+	ops := []painter.Operation{
+		painter.NewTFigure(0.1, 0.1),
+		painter.Gesture{Operation: painter.NewMoveShape(1, 0.6, 0), ID: 1},
+		painter.Gesture{Operation: painter.NewMoveShape(1, 0.6, 0), ID: 1},
+		painter.Gesture{Operation: painter.NewRotate(1, 200, &around), ID: 2},
+		painter.Gesture{Operation: painter.NewRotate(1, 200, &around), ID: 2},
+		painter.Gesture{Operation: painter.NewRotate(1, 300, &around), ID: 2},
+		painter.Gesture{Operation: painter.NewTransform(1, painter.Scaling(painter.Point{X: 1.8, Y: 1.8}, painter.Point{X: 0.9, Y: 0.9})), ID: 3},
+		painter.Gesture{Operation: painter.NewTransform(1, painter.Scaling(painter.Point{X: 1.8, Y: 1.8}, painter.Point{X: 0.9, Y: 0.9})), ID: 3},
+
+		// resize handles stretch around a corner, which may be off the canvas
+		painter.Gesture{Operation: painter.NewScale(1, 3, 3, &painter.Point{X: 1.1, Y: -0.1}), ID: 4},
+		painter.Gesture{Operation: painter.NewScale(1, 0.5, 0.5, &painter.Point{X: 1.1, Y: -0.1}), ID: 4},
+	}
+
+	l.Start(nil)
+
+	for _, op := range ops {
+		l.PostOperation(op)
+		<-done
+	}
+
+	l.Terminate()
+
+	rec := httptest.NewRecorder()
+	HistoryHandler(&l).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history", nil))
+	script := rec.Body.String()
+
+	p := Parser{}
+	res, err := p.ParseOperations(strings.NewReader(script))
+
+	if err != nil || len(res) == 0 {
+		t.Fatalf("history %q can not be parsed back: %v", script, err)
+	}
+
+	// the history is posted to a fresh painter the way a client does it
+	replayed := painter.Generator{}
+	replayedDone := make(frames)
+	replay := painter.Loop{Gen: &replayed, Receiver: replayedDone}
+	replay.Start(nil)
+
+	post := httptest.NewRecorder()
+	HttpHandler(&replay, &Parser{}).ServeHTTP(post, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(script)))
+
+	if post.Code != http.StatusOK {
+		t.Fatalf("history %q is not accepted: %s", script, post.Body.String())
+	}
+
+	for range res {
+		<-replayedDone
+	}
+
+	replay.Terminate()
+
+	got, expected := replayed.GetTFigures()[0], gen.GetTFigures()[0]
+
+	if math.Abs(got.Center.X-expected.Center.X) > 1e-9 || math.Abs(got.Center.Y-expected.Center.Y) > 1e-9 {
+		t.Errorf("replayed figure is at %v, expected %v", got.Center, expected.Center)
+	}
+
+	if got, expected := replayed.RenderImage(image.Pt(100, 100)), gen.RenderImage(image.Pt(100, 100)); !bytes.Equal(got.Pix, expected.Pix) {
+		t.Errorf("replayed scene differs from the one the history is taken from")
+	}
+}
@@ -0,0 +1,66 @@
+package lang
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func FuzzGetOperation(f *testing.F) {
+	for _, command := range []string{"white", "figure 0.5 0.5", "brect 0 0 1 1", "move -0.1 1e-2", "update", "reset"} {
+		f.Add(command)
+	}
+
+	f.Fuzz(func(t *testing.T, command string) {
+		op, err := GetOperation(command)
+
+		if err != nil && op != nil {
+			t.Errorf("GetOperation(%q) returned both %v and an error: %s", command, op, err)
+		}
+	})
+}
+
+func FuzzParseOperations(f *testing.F) {
+	for _, script := range []string{
+		"white\nupdate",
+		"figure 0.25 0.25 & move 0.1 0.1\nupdate",
+		"brect 0.75 0.75 0.25 0.25\nupdate\nreset",
+		"reset & white & update & green",
+		"figure NaN +Inf\nupdate",
+		"move 1e300 -0x1p-2\t\v\nupdate",
+	} {
+		f.Add(script)
+	}
+
+	f.Fuzz(func(t *testing.T, script string) {
+		p := Parser{}
+		ops, err := p.ParseOperations(strings.NewReader(script))
+
+		if err != nil {
+			return
+		}
+
+		// parse -> format -> parse has to give the same operations back
+		var formatted bytes.Buffer
+
+		if err := (&Parser{}).Format(strings.NewReader(script), &formatted); err != nil {
+			t.Fatalf("script %q is parsed, but can not be formatted: %s", script, err)
+		}
+
+		p = Parser{}
+		formattedOps, err := p.ParseOperations(&formatted)
+
+		if err != nil {
+			t.Fatalf("formatted script %q can not be parsed: %s", formatted.String(), err)
+		}
+
+		if strings.Contains(formatted.String(), "NaN") || strings.Contains(formatted.String(), "Inf") {
+			t.Errorf("script %q is accepted with non-finite arguments", script)
+		}
+
+		if !reflect.DeepEqual(ops, formattedOps) {
+			t.Errorf("script %q gives %v, but its formatted form %q gives %v", script, ops, formatted.String(), formattedOps)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package lang
 
 import (
+	"bytes"
 	"io"
 	"net/http"
 	"strings"
@@ -48,3 +49,53 @@ func HttpHandler(loop *painter.Loop, p *Parser) http.Handler {
 		rw.WriteHeader(http.StatusOK)
 	})
 }
+
+// HistoryHandler answers `GET /history` with the operations done by the
+// loop as a script ending with `update`, posting it to a fresh painter
+// builds the same scene.
+func HistoryHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.Header().Set("Allow", http.MethodGet)
+			http.Error(rw, "Only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var script bytes.Buffer
+
+		if err := FormatOperations(&script, append(loop.History(), painter.UpdatePoint{})); err != nil {
+			log.Println(err)
+			http.Error(rw, "An error occurred: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = rw.Write(script.Bytes())
+	})
+}
+
+// maxAssetSize limits the size of uploaded images in bytes.
+const maxAssetSize = 32 << 20
+
+// AssetsHandler stores images sent as `POST /assets?name=<asset>`, which can
+// be shown afterwards with the `image` command.
+func AssetsHandler(gen *painter.Generator) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, "Only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		err := gen.AddAsset(name, http.MaxBytesReader(rw, r.Body, maxAssetSize))
+
+		if err != nil {
+			log.Println(err)
+			http.Error(rw, "An error occurred: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+	})
+}
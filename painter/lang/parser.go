@@ -1,10 +1,10 @@
 package lang
 
 import (
+	"errors"
 	"fmt"
+	"image/color"
 	"io"
-	"regexp"
-	"strconv"
 
 	"bufio"
 	"github.com/magicvegetable/architecture-lab-3/painter"
@@ -12,135 +12,311 @@ import (
 )
 
 type Parser struct {
+	// Policy decides what happens to coordinates outside of the canvas.
+	Policy CoordinatePolicy
+
 	savedOperationsPool []painter.Operation
 }
 
 var table = painter.GetTable()
 
 func GetOperation(command string) (painter.Operation, error) {
+	return (&Parser{}).GetOperation(command)
+}
+
+func (p *Parser) GetOperation(command string) (painter.Operation, error) {
 	command = strings.TrimSpace(command)
 
 	if command == "" {
 		return nil, nil
 	}
 
-	args := []string{command}
-	spacex := regexp.MustCompile(`\s+`)
+	args, err := splitArgs(command)
 
-	if spacex.MatchString(command) {
-		args = spacex.Split(command, -1)
+	if err != nil {
+		return nil, err
 	}
 
 	fn, ok := table[args[0]]
 	if !ok {
 		errMessage := fmt.Sprintf("Get wrong command `%s`, no such a operation as `%s` in the table", command, args[0])
-		return nil, fmt.Errorf(errMessage)
+		return nil, errors.New(errMessage)
 	}
 
+	name := args[0]
 	args = args[1:]
 
-	switch fn := fn.(type) {
-	case painter.FillCreateFn:
-		return fn(), nil
+	var c color.RGBA
+	var label, asset string
+	var gradientID int
+	var gradient painter.Gradient
+	var dash []float64
+	var elementName string
+	var groupIDs []int
+	var groupNames []string
+	var edge painter.AlignEdge
+	var axis painter.Axis
+
+	spec := name
+
+	switch fn.(type) {
+	case painter.CreateFill:
+		if len(args) == 0 || !isColor(args[len(args)-1]) {
+			return nil, fmt.Errorf("operation `%s` needs a color", name)
+		}
+
+		args, c, err = splitColor(args, c)
 
 	case painter.CreateTFigureFn:
-		if lenArgs := len(args); lenArgs != 2 {
-			errMessage := fmt.Sprintf(
-				"wrong len(%d) of args for operation type %T, amount have to be %d",
-				lenArgs, fn, 2,
-			)
+		args, c, err = splitColor(args, painter.TFigureColor)
 
-			return nil, fmt.Errorf(errMessage)
+	case painter.CreateText:
+		if len(args) < 3 || args[2] == "" {
+			return nil, fmt.Errorf("operation `%s` needs a label after the position", name)
 		}
-		var x, y float64
-		var err error
 
-		x, err = strconv.ParseFloat(args[0], 64)
-		if err != nil {
-			return nil, err
+		label = args[2]
+		args = append(args[:2:2], args[3:]...)
+		args, c, err = splitColor(args, painter.TextColor)
+
+	case painter.CreatePicture:
+		if len(args) == 0 || !painter.ValidAssetName(args[0]) {
+			return nil, fmt.Errorf("operation `%s` needs an asset name before the position", name)
 		}
 
-		y, err = strconv.ParseFloat(args[1], 64)
-		if err != nil {
-			return nil, err
+		asset = args[0]
+		args = args[1:]
+
+	case painter.CreateGradient:
+		gradientID, gradient, args, err = p.splitGradient(args)
+		spec = name + " " + gradient.Kind.String()
+
+	case painter.CreateLayer, painter.CreateShowLayer, painter.CreateHideLayer, painter.CreateLayerOpacity:
+		if len(args) == 0 || !painter.ValidName(args[0]) {
+			return nil, fmt.Errorf("operation `%s` needs a layer name", name)
 		}
 
-		return fn(x, y), nil
+		elementName = args[0]
+		args = args[1:]
 
-	case painter.CreateBRect:
-		if lenArgs := len(args); lenArgs != 4 {
-			errMessage := fmt.Sprintf(
-				"wrong len(%d) of args for operation type %T, amount have to be %d",
-				lenArgs, fn, 4,
-			)
+	case painter.CreateGroup, painter.CreateUngroup:
+		if len(args) == 0 || !painter.ValidName(args[0]) {
+			return nil, fmt.Errorf("operation `%s` needs a group name", name)
+		}
+
+		elementName = args[0]
+		args = args[1:]
 
-			return nil, fmt.Errorf(errMessage)
+		if _, ok := fn.(painter.CreateGroup); ok {
+			groupIDs, groupNames, err = p.splitMembers(args)
+			args = nil
 		}
-		var x1, y1, x2, y2 float64
-		var err error
 
-		x1, err = strconv.ParseFloat(args[0], 64)
-		if err != nil {
-			return nil, err
+	case painter.CreateSetStroke, painter.CreateLine:
+		args, c, err = splitColor(args, painter.StrokeColor)
+		if err == nil {
+			args, dash, err = p.splitDash(args, len(ArgSpecs[name]))
 		}
 
-		y1, err = strconv.ParseFloat(args[1], 64)
-		if err != nil {
-			return nil, err
+	case painter.CreateAlign:
+		ok := len(args) > 0
+		if ok {
+			edge, ok = painter.ParseAlignEdge(args[0])
 		}
+		if !ok {
+			return nil, fmt.Errorf("operation `%s` needs one of left, center, right, top, middle or bottom", name)
+		}
+
+		groupIDs, err = p.splitIDs(args[1:], 2)
+		args = nil
 
-		x2, err = strconv.ParseFloat(args[2], 64)
-		if err != nil {
-			return nil, err
+	case painter.CreateDistribute:
+		ok := len(args) > 0
+		if ok {
+			axis, ok = painter.ParseAxis(args[0])
 		}
+		if !ok {
+			return nil, fmt.Errorf("operation `%s` needs horizontal or vertical", name)
+		}
+
+		groupIDs, err = p.splitIDs(args[1:], 3)
+		args = nil
 
-		y2, err = strconv.ParseFloat(args[3], 64)
-		if err != nil {
-			return nil, err
+	case painter.CreateMove, painter.CreateMoveTo:
+		if len(args) == len(ArgSpecs[name+" id"]) {
+			spec = name + " id"
 		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("operation `%s`: %w", name, err)
+	}
 
-		return fn(x1, y1, x2, y2), nil
+	values, err := p.parseArgs(spec, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch fn := fn.(type) {
+	case painter.FillCreateFn:
+		return fn(), nil
+
+	case painter.CreateFill:
+		return fn(c), nil
+
+	case painter.CreateTFigureFn:
+		if len(values) == 2 {
+			return fn(values[0], values[1], painter.TFigureSize.X, painter.TFigureSize.Y, c), nil
+		}
+		return fn(values[0], values[1], values[2], values[3], c), nil
+
+	case painter.CreateBRect:
+		return fn(values[0], values[1], values[2], values[3]), nil
 
 	case painter.CreateMove:
-		if lenArgs := len(args); lenArgs != 2 {
-			errMessage := fmt.Sprintf(
-				"wrong len(%d) of args for operation type %T, amount have to be %d",
-				lenArgs, fn, 2,
-			)
-			return nil, fmt.Errorf(errMessage)
+		if len(values) == 3 {
+			return painter.NewMoveShape(int(values[0]), values[1], values[2]), nil
 		}
-		var x, y float64
-		var err error
+		return fn(values[0], values[1]), nil
 
-		x, err = strconv.ParseFloat(args[0], 64)
-		if err != nil {
-			return nil, err
+	case painter.CreateMoveTo:
+		if len(values) == 3 {
+			return painter.NewMoveShapeTo(int(values[0]), values[1], values[2]), nil
 		}
+		return fn(values[0], values[1]), nil
 
-		y, err = strconv.ParseFloat(args[1], 64)
-		if err != nil {
-			return nil, err
+	case painter.CreateZoom:
+		if len(values) == 1 {
+			return fn(values[0], nil), nil
 		}
+		return fn(values[0], &painter.Point{X: values[1], Y: values[2]}), nil
 
-		return fn(x, y), nil
+	case painter.CreatePan:
+		return fn(values[0], values[1]), nil
 
-	case painter.UpdatePoint:
-		if len(args) != 0 {
-			return nil, fmt.Errorf("no support for multiple instruction per line")
+	case painter.Fit:
+		return fn, nil
+
+	case painter.CreateRotate:
+		if len(values) == 2 {
+			return fn(int(values[0]), values[1], nil), nil
+		}
+		return fn(int(values[0]), values[1], &painter.Point{X: values[2], Y: values[3]}), nil
+
+	case painter.CreateScale:
+		if values[1] == 0 || values[2] == 0 {
+			return nil, fmt.Errorf("operation `%s`: scale factors can not be zero", name)
+		}
+		if len(values) == 3 {
+			return fn(int(values[0]), values[1], values[2], nil), nil
+		}
+		return fn(int(values[0]), values[1], values[2], &painter.Point{X: values[3], Y: values[4]}), nil
+
+	case painter.CreateText:
+		size := painter.TextSize
+		if len(values) == 3 {
+			size = values[2]
+		}
+		return fn(values[0], values[1], label, size, c), nil
+
+	case painter.CreatePicture:
+		return fn(asset, values[0], values[1], values[2], values[3]), nil
+
+	case painter.CreateGradient:
+		gradient.From = painter.Point{X: values[0], Y: values[1]}
+		if gradient.Kind == painter.RadialGradient {
+			gradient.Radius = values[2]
+		} else {
+			gradient.To = painter.Point{X: values[2], Y: values[3]}
+			if gradient.From == gradient.To {
+				return nil, fmt.Errorf("operation `%s`: the gradient line can not be empty", name)
+			}
+		}
+		return fn(gradientID, gradient), nil
+
+	case painter.CreateSetStroke:
+		return fn(int(values[0]), values[1], dash, c), nil
+
+	case painter.CreateLine:
+		return fn(values[0], values[1], values[2], values[3], values[4], dash, c), nil
+
+	case painter.CreateLayer:
+		if len(values) == 0 {
+			return fn(elementName, nil), nil
 		}
+		z := int(values[0])
+		return fn(elementName, &z), nil
+
+	case painter.CreateShowLayer:
+		return fn(elementName), nil
+
+	case painter.CreateHideLayer:
+		return fn(elementName), nil
+
+	case painter.CreateLayerOpacity:
+		return fn(elementName, values[0]), nil
+
+	case painter.CreateBringToFront:
+		return fn(int(values[0])), nil
+
+	case painter.CreateSendToBack:
+		return fn(int(values[0])), nil
+
+	case painter.CreateGroup:
+		return fn(elementName, groupIDs, groupNames), nil
 
+	case painter.CreateUngroup:
+		return fn(elementName), nil
+
+	case painter.CreateClone:
+		if len(values) == 1 {
+			return fn(int(values[0]), 0, 0), nil
+		}
+		return fn(int(values[0]), values[1], values[2]), nil
+
+	case painter.CreateAlign:
+		return fn(edge, groupIDs), nil
+
+	case painter.CreateDistribute:
+		return fn(axis, groupIDs), nil
+
+	case painter.CreateDelete:
+		return fn(int(values[0])), nil
+
+	case painter.CreateResize:
+		return fn(int(values[0]), values[1], values[2]), nil
+
+	case painter.CreateTransform:
+		m := painter.Affine{
+			A: values[1], B: values[2], C: values[3], D: values[4], E: values[5], F: values[6],
+		}
+		if m.Det() == 0 {
+			return nil, fmt.Errorf("operation `%s`: the matrix can not be inverted", name)
+		}
+		return fn(int(values[0]), m), nil
+
+	case painter.UpdatePoint:
 		return fn, nil
 
 	case painter.Reset:
-		if len(args) != 0 {
-			return nil, fmt.Errorf("no support for multiple instruction per line")
+		return fn, nil
+
+	case painter.Undo:
+		return fn, nil
+
+	case painter.CreateSetGrid:
+		if len(values) == 0 {
+			return fn(0), nil
 		}
+		return fn(values[0]), nil
 
+	case painter.ToggleSnap:
 		return fn, nil
 	}
 
-	errMessage := fmt.Sprintf("Handler not implemented for such of operation as `%s` yet", fn)
-	return nil, fmt.Errorf(errMessage)
+	errMessage := fmt.Sprintf("Handler not implemented for such of operation as `%s` yet", name)
+	return nil, errors.New(errMessage)
 }
 
 func (p *Parser) ParseOperations(in io.Reader) ([]painter.Operation, error) {
@@ -153,10 +329,10 @@ func (p *Parser) ParseOperations(in io.Reader) ([]painter.Operation, error) {
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		ops := strings.Split(line, "&")
+		ops := splitCommands(line)
 
 		for _, op := range ops {
-			op, err := GetOperation(op)
+			op, err := p.GetOperation(op)
 
 			if err != nil {
 				return nil, err
@@ -181,6 +357,10 @@ func (p *Parser) ParseOperations(in io.Reader) ([]painter.Operation, error) {
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
 	if updateToIndex == -1 {
 		p.savedOperationsPool = append(p.savedOperationsPool, parsedOps...)
 		return []painter.Operation{}, nil
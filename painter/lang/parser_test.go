@@ -49,6 +49,16 @@ func TestParseOperations(t *testing.T) {
 			input:  bytes.NewBufferString("move 0.25 0.25\nupdate"),
 			result: []painter.Operation{painter.NewMove(0.25, 0.25)},
 		},
+		{
+			name:   "moveto",
+			input:  bytes.NewBufferString("moveto 0.75 0.25\nupdate"),
+			result: []painter.Operation{painter.NewMoveTo(0.75, 0.25)},
+		},
+		{
+			name:   "moveto-id",
+			input:  bytes.NewBufferString("moveto 2 0.75 0.25\nupdate"),
+			result: []painter.Operation{painter.NewMoveShapeTo(2, 0.75, 0.25)},
+		},
 		{
 			name:   "reset",
 			input:  bytes.NewBufferString("reset"),
@@ -235,3 +245,117 @@ func TestParseOperations(t *testing.T) {
 
 	checkCasesFn(complexCases)
 }
+
+func TestGetOperationValidation(t *testing.T) {
+	rejected := []string{
+		"figure NaN 0.5",
+		"figure 0.5 +Inf",
+		"figure 1e300 0.5",
+		"figure 0.5 1e400",
+		"brect 0 0 1 1.5",
+		"move -2 0",
+		"move 0 0.1 0.1",
+		"move 1.5 0.1 0.1",
+		"move 1 2 0",
+		"figure 0.5",
+		"white 1",
+		"fill",
+		"moveto 0.5",
+		"moveto 0 0.5 0.5",
+		"moveto 2 0.5 1.5",
+		"fill 0.5",
+		"fill red blue",
+		"reset now",
+		"undo 1",
+		"grid 0",
+		"grid 1",
+		"grid 0.1 0.1",
+		"snap on",
+		"clone",
+		"clone 0",
+		"clone 1 0.1",
+		"clone 1 2 0",
+		"align",
+		"align left 1",
+		"align across 1 2",
+		"align left 1 x",
+		"distribute horizontal 1 2",
+		"distribute sideways 1 2 3",
+		"rotate 1.5 90",
+		"rotate 0 90",
+		"rotate 1 90 0.5",
+		"scale 1 0 1",
+		"transform 1 1 2 2 4 0 0",
+		"figure 0.5 0.5 0.1",
+		"figure 0.5 0.5 #12345",
+		"figure 0.5 0.5 red blue",
+		"resize 1 0 0.1",
+		"text 0.1 0.1",
+		"text 0.1 0.1 \"\"",
+		"text 0.1 0.1 \"not closed",
+		"text 0.1 0.1 \"label\"0.1",
+		"text 0.1 0.1 label 1",
+		"image 0.1 0.1 0.5 0.5",
+		"image ../logo.png 0.1 0.1 0.5 0.5",
+		"image logo.png 0.1 0.1 0.5",
+		"gradient linear 0 0 1 0 red",
+		"gradient linear 0.5 0.5 0.5 0.5 red blue",
+		"gradient radial 0.5 0.5 0 red blue",
+		"gradient conic 0.5 0.5 0.5 red blue",
+		"gradient 0 linear 0 0 1 0 red blue",
+		"gradient linear 0 0 1 red blue",
+		"line 0 0 1 1",
+		"line 0 0 1 1 0",
+		"line 0 0 1 1 0.01 0.05 red blue",
+		"stroke 1 0.01 0",
+		"stroke 1 0.01 0.1 0.1 0.1 0.1 0.1 0.1 0.1 0.1 0.1",
+		"stroke 1",
+		"layer",
+		"layer 2d",
+		"layer top 1.5",
+		"hide top 1",
+		"opacity top 2",
+		"bring-to-front",
+		"send-to-back top",
+		"group",
+		"group g",
+		"group 1 2",
+		"group g 0",
+		"group g 1.5",
+		"ungroup",
+		"ungroup g 1",
+		"delete",
+		"delete g",
+	}
+
+	for _, command := range rejected {
+		if op, err := GetOperation(command); err == nil {
+			t.Errorf("`%s` has to be rejected, got %v", command, op)
+		}
+	}
+
+	p := Parser{Policy: ClampToCanvas}
+
+	clamped := map[string]painter.Operation{
+		"figure 1e300 -0.5":  painter.NewTFigure(1, 0),
+		"brect -1 0.5 2 0.5": painter.NewBRect(0, 0.5, 1, 0.5),
+		"move -3 0.25":       painter.NewMove(-1, 0.25),
+	}
+
+	for command, expected := range clamped {
+		op, err := p.GetOperation(command)
+
+		if err != nil {
+			t.Errorf("`%s`: %s", command, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(op, expected) {
+			t.Errorf("`%s` gives %v, expected %v", command, op, expected)
+		}
+	}
+
+	if _, err := p.GetOperation("figure NaN 0.5"); err == nil {
+		t.Errorf("NaN has to be rejected even when coordinates are clamped")
+	}
+}
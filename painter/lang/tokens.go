@@ -0,0 +1,90 @@
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// quotedEnd returns the index right after the closing quote of the string
+// starting at s[0], or -1 when it is not closed.
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// splitCommands splits a line into the commands separated by `&`, which
+// are not inside of quotes.
+func splitCommands(line string) []string {
+	commands := []string{}
+	start := 0
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			end := quotedEnd(line[i:])
+			if end < 0 {
+				return append(commands, line[start:])
+			}
+			i += end - 1
+		case '&':
+			commands = append(commands, line[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(commands, line[start:])
+}
+
+// splitArgs splits a command into arguments separated by spaces, an
+// argument in double quotes may contain spaces and Go escape sequences.
+func splitArgs(command string) ([]string, error) {
+	args := []string{}
+
+	for command = strings.TrimLeftFunc(command, unicode.IsSpace); command != ""; command = strings.TrimLeftFunc(command, unicode.IsSpace) {
+		if command[0] == '"' {
+			end := quotedEnd(command)
+
+			if end < 0 {
+				return nil, fmt.Errorf("quoted argument %s is not closed", command)
+			}
+
+			arg, err := strconv.Unquote(command[:end])
+
+			if err != nil {
+				return nil, fmt.Errorf("wrong quoted argument %s", command[:end])
+			}
+
+			if end < len(command) {
+				if r, _ := utf8.DecodeRuneInString(command[end:]); !unicode.IsSpace(r) {
+					return nil, fmt.Errorf("quoted argument %s has to be followed by a space", command[:end])
+				}
+			}
+
+			args = append(args, arg)
+			command = command[end:]
+
+			continue
+		}
+
+		end := strings.IndexFunc(command, unicode.IsSpace)
+		if end < 0 {
+			end = len(command)
+		}
+
+		args = append(args, command[:end])
+		command = command[end:]
+	}
+
+	return args, nil
+}
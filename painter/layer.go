@@ -0,0 +1,260 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// DefaultLayer holds the shapes added before any layer is chosen.
+const DefaultLayer = "default"
+
+// ValidName tells whether the name can be given to a layer or a group: it
+// starts with a letter and goes on with letters, digits, `-` and `_`.
+func ValidName(name string) bool {
+	for i, r := range name {
+		letter := 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+
+		if !letter && (i == 0 || !('0' <= r && r <= '9' || r == '-' || r == '_')) {
+			return false
+		}
+	}
+
+	return name != ""
+}
+
+// layer keeps its shapes in the order they are drawn, layers with a
+// greater z are drawn above.
+type layer struct {
+	name    string
+	z       int
+	hidden  bool
+	opacity float64
+	shapes  []Shape
+}
+
+// translucentLayer draws its shapes on their own and blends the result
+// with what is below.
+type translucentLayer struct {
+	opacity float64
+	shapes  []Shape
+}
+
+func (tl translucentLayer) Draw(c Canvas, vp Viewport) {
+	ic, ok := c.(ImageCanvas)
+
+	// canvases unable to blend get the shapes as they are
+	if !ok {
+		for _, sh := range tl.shapes {
+			sh.Draw(c, vp)
+		}
+		return
+	}
+
+	bounds := ic.Bounds()
+	off := ImageCanvas{RGBA: image.NewRGBA(bounds), Antialias: ic.Antialias}
+
+	for _, sh := range tl.shapes {
+		sh.Draw(off, vp)
+	}
+
+	mask := image.NewUniform(color.Alpha{A: uint8(math.Round(tl.opacity * 0xff))})
+	draw.DrawMask(ic.RGBA, bounds, off.RGBA, bounds.Min, mask, image.Point{}, draw.Over)
+}
+
+// currentLayer returns the layer new shapes are added to, the store has to
+// be locked by the caller.
+func (gn *Generator) currentLayer() *layer {
+	if gn.store.current == nil {
+		gn.store.current = gn.layerNamed(DefaultLayer)
+	}
+
+	return gn.store.current
+}
+
+// layerNamed returns the layer with the name, a missing one is created on
+// top of the others. The store has to be locked by the caller.
+func (gn *Generator) layerNamed(name string) *layer {
+	for _, l := range gn.store.layers {
+		if l.name == name {
+			return l
+		}
+	}
+
+	l := &layer{name: name, opacity: 1}
+
+	if last := len(gn.store.layers) - 1; last >= 0 {
+		l.z = gn.store.layers[last].z + 1
+	}
+
+	gn.store.layers = append(gn.store.layers, l)
+
+	return l
+}
+
+// findLayer looks for an existing layer with the name in the store, which
+// has to be locked by the caller.
+func (gn *Generator) findLayer(name string) (*layer, bool) {
+	for _, l := range gn.store.layers {
+		if l.name == name {
+			return l, true
+		}
+	}
+
+	log.Printf("no layer named %s", name)
+
+	return nil, false
+}
+
+func (gn *Generator) sortLayers() {
+	sort.SliceStable(gn.store.layers, func(i, j int) bool {
+		return gn.store.layers[i].z < gn.store.layers[j].z
+	})
+}
+
+// locateShape returns the layer of the shape with the id and its index in
+// the layer, the store has to be locked by the caller.
+func (gn *Generator) locateShape(id int) (*layer, int, bool) {
+	for _, l := range gn.store.layers {
+		for i, sh := range l.shapes {
+			if sh.GetID() == id {
+				return l, i, true
+			}
+		}
+	}
+
+	return nil, 0, false
+}
+
+// restack moves the shape with the id to the top or the bottom of its layer,
+// the store has to be locked by the caller.
+func (gn *Generator) restack(id int, top bool) {
+	l, i, ok := gn.locateShape(id)
+
+	if !ok {
+		log.Printf("no shape with id %d", id)
+		return
+	}
+
+	sh := l.shapes[i]
+	l.shapes = append(l.shapes[:i], l.shapes[i+1:]...)
+
+	if top {
+		l.shapes = append(l.shapes, sh)
+	} else {
+		l.shapes = append([]Shape{sh}, l.shapes...)
+	}
+}
+
+// Layer makes the layer with the Name current, so the next shapes are added
+// to it, and moves it to the Z level when that is given.
+type Layer struct {
+	Name string
+	Z    *int
+}
+
+func (l Layer) String() string {
+	if l.Z == nil {
+		return "layer " + l.Name
+	}
+
+	return "layer " + l.Name + " " + strconv.Itoa(*l.Z)
+}
+
+func (l Layer) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func NewLayer(name string, z *int) Layer {
+	return Layer{Name: name, Z: z}
+}
+
+type ShowLayer struct {
+	Name string
+}
+
+func (sl ShowLayer) String() string {
+	return "show " + sl.Name
+}
+
+func (sl ShowLayer) MarshalText() ([]byte, error) {
+	return []byte(sl.String()), nil
+}
+
+func NewShowLayer(name string) ShowLayer {
+	return ShowLayer{Name: name}
+}
+
+type HideLayer struct {
+	Name string
+}
+
+func (hl HideLayer) String() string {
+	return "hide " + hl.Name
+}
+
+func (hl HideLayer) MarshalText() ([]byte, error) {
+	return []byte(hl.String()), nil
+}
+
+func NewHideLayer(name string) HideLayer {
+	return HideLayer{Name: name}
+}
+
+// LayerOpacity blends the layer with the Name with the ones below, zero
+// makes it invisible and one opaque.
+type LayerOpacity struct {
+	Name    string
+	Opacity float64
+}
+
+func (lo LayerOpacity) String() string {
+	return "opacity " + lo.Name + " " + formatFloat(lo.Opacity)
+}
+
+func (lo LayerOpacity) MarshalText() ([]byte, error) {
+	return []byte(lo.String()), nil
+}
+
+func NewLayerOpacity(name string, opacity float64) LayerOpacity {
+	return LayerOpacity{Name: name, Opacity: opacity}
+}
+
+// BringToFront puts the shape with the ID above the others of its layer.
+type BringToFront struct {
+	ID int
+}
+
+func (bf BringToFront) String() string {
+	return "bring-to-front " + strconv.Itoa(bf.ID)
+}
+
+func (bf BringToFront) MarshalText() ([]byte, error) {
+	return []byte(bf.String()), nil
+}
+
+func NewBringToFront(id int) BringToFront {
+	return BringToFront{ID: id}
+}
+
+// SendToBack puts the shape with the ID below the others of its layer.
+type SendToBack struct {
+	ID int
+}
+
+func (sb SendToBack) String() string {
+	return "send-to-back " + strconv.Itoa(sb.ID)
+}
+
+func (sb SendToBack) MarshalText() ([]byte, error) {
+	return []byte(sb.String()), nil
+}
+
+func NewSendToBack(id int) SendToBack {
+	return SendToBack{ID: id}
+}
@@ -0,0 +1,87 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// LetterboxColor fills the window around the canvas when it does not take
+// the whole window.
+var LetterboxColor = color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+
+// ScaleMode tells how the canvas is put into a window of another shape.
+type ScaleMode int
+
+const (
+	// ScaleStretch gives the whole window to the canvas, so shapes stretch
+	// with it.
+	ScaleStretch ScaleMode = iota
+
+	// ScaleFit shows the whole canvas in the middle of the window with bars
+	// along the sides left over.
+	ScaleFit
+
+	// ScaleFill covers the window with the canvas, cutting off its sides
+	// sticking out.
+	ScaleFill
+)
+
+var scaleModeNames = []string{"stretch", "fit", "fill"}
+
+func (s ScaleMode) String() string {
+	return scaleModeNames[s]
+}
+
+func ParseScaleMode(s string) (ScaleMode, bool) {
+	for i, name := range scaleModeNames {
+		if name == s {
+			return ScaleMode(i), true
+		}
+	}
+
+	return 0, false
+}
+
+// canvasBounds returns the pixels of a window of the size taken by the
+// canvas, they go beyond the window when the canvas fills it.
+func (gn *Generator) canvasBounds(size image.Point) image.Rectangle {
+	window := image.Rectangle{Max: size}
+
+	if gn.Aspect <= 0 || gn.Scale == ScaleStretch || size.X == 0 || size.Y == 0 {
+		return window
+	}
+
+	w, h := float64(size.X), float64(size.Y)
+
+	if wider := w/h > gn.Aspect; wider == (gn.Scale == ScaleFit) {
+		w = h * gn.Aspect
+	} else {
+		h = w / gn.Aspect
+	}
+
+	min := image.Point{
+		X: int(math.Round((float64(size.X) - w) / 2)),
+		Y: int(math.Round((float64(size.Y) - h) / 2)),
+	}
+
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(int(math.Round(w)), int(math.Round(h))))}
+}
+
+// drawLetterbox covers the canvas outside of the bounds with the bars.
+func drawLetterbox(c Canvas, bounds image.Rectangle) {
+	all := c.Bounds()
+
+	bars := []image.Rectangle{
+		{Min: all.Min, Max: image.Pt(all.Max.X, bounds.Min.Y)},
+		{Min: image.Pt(all.Min.X, bounds.Max.Y), Max: all.Max},
+		{Min: image.Pt(all.Min.X, bounds.Min.Y), Max: image.Pt(bounds.Min.X, bounds.Max.Y)},
+		{Min: image.Pt(bounds.Max.X, bounds.Min.Y), Max: image.Pt(all.Max.X, bounds.Max.Y)},
+	}
+
+	for _, bar := range bars {
+		fill(c, bar, LetterboxColor, screen.Src)
+	}
+}
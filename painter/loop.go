@@ -1,5 +1,6 @@
 package painter
 
+import "math"
 import "sync"
 import "golang.org/x/exp/shiny/screen"
 
@@ -47,10 +48,11 @@ func (q *operationQueue) Pull() Operation {
 	}
 
 	if q.head == nil {
-		q.blocked = make(chan struct{})
+		blocked := make(chan struct{})
+		q.blocked = blocked
 		q.m.Unlock()
 
-		<-q.blocked
+		<-blocked
 		q.m.Lock()
 
 		if q.terminate {
@@ -63,6 +65,18 @@ func (q *operationQueue) Pull() Operation {
 	return op
 }
 
+// Len returns the number of operations waiting in the queue.
+func (q *operationQueue) Len() (n int) {
+	defer q.m.Unlock()
+	q.m.Lock()
+
+	for e := q.head; e != nil; e = e.next {
+		n++
+	}
+
+	return
+}
+
 type Receiver interface {
 	Update()
 }
@@ -74,16 +88,30 @@ type Loop struct {
 
 	terminated chan struct{}
 
+	// history holds the operations done by the loop, so the scene can be
+	// built again by replaying them.
+	history  []entry
+	historyM sync.Mutex
+
+	// base holds the operations done before the loop started, undo builds
+	// the scene from them.
+	base []Operation
+
 	Gen TextureGenerator
 }
 
 func (l *Loop) Start(scr screen.Screen) {
 	l.Gen.SetScreen(scr)
 
-	go func() {
-		l.terminated = make(chan struct{})
-		l.queue.terminate = false
+	// the state is reset before the goroutine starts, so operations
+	// posted and terminations asked for right away are not raced with
+	l.terminated = make(chan struct{})
+
+	l.queue.m.Lock()
+	l.queue.terminate = false
+	l.queue.m.Unlock()
 
+	go func() {
 		for {
 			op := l.queue.Pull()
 
@@ -91,7 +119,17 @@ func (l *Loop) Start(scr screen.Screen) {
 				break
 			}
 
-			l.Gen.Update(op)
+			gesture := 0
+			if g, ok := op.(Gesture); ok {
+				op, gesture = g.Operation, g.ID
+			}
+
+			if _, ok := op.(Undo); ok {
+				l.undo()
+			} else {
+				l.Gen.Update(op)
+				l.record(op, gesture)
+			}
 
 			l.Receiver.Update()
 		}
@@ -115,6 +153,183 @@ func (l *Loop) Terminate() {
 	<-l.terminated
 }
 
+// Gesture is an operation posted during a single drag of the mouse or a
+// single nudge of the selection. The history adds up the operations of a
+// gesture and undo takes them back together.
+type Gesture struct {
+	Operation
+
+	// ID tells the gestures apart, operations with the same ID belong to
+	// the same gesture.
+	ID int
+}
+
+// entry is an operation of the history with the gesture it was done by,
+// the gesture is zero for operations done on their own.
+type entry struct {
+	op      Operation
+	gesture int
+}
+
+// record appends the operation to the history. Moves, turns, scales and
+// transforms of a shape are added up with the ones done earlier by the same
+// gesture, so a drag ends up as a single entry for every shape dragged. A
+// gesture posts the operations for distinct groups of shapes, so adding them
+// up keeps the scene the same. A sum going past the limits of its command
+// starts a new entry instead, so the history can always be parsed back.
+func (l *Loop) record(op Operation, gesture int) {
+	defer l.historyM.Unlock()
+
+	l.historyM.Lock()
+
+	for i := len(l.history) - 1; gesture != 0 && i >= 0 && l.history[i].gesture == gesture; i-- {
+		earlier := l.history[i].op
+
+		if sum, ok := merge(earlier, op); ok {
+			if replayable(sum) {
+				l.history[i].op = sum
+				return
+			}
+			break
+		}
+
+		// operations on the same shape can not be added up past each other
+		if a, b := shapeOf(earlier), shapeOf(op); a == 0 || b == 0 || a == b {
+			break
+		}
+	}
+
+	l.history = append(l.history, entry{op: op, gesture: gesture})
+}
+
+// merge returns the operation doing both earlier and op on the same shape,
+// when there is one.
+func merge(earlier, op Operation) (Operation, bool) {
+	switch op := op.(type) {
+	case Move:
+		if last, ok := earlier.(Move); ok && op.ID != 0 && last.ID == op.ID {
+			return NewMoveShape(op.ID, last.Dest.X+op.Dest.X, last.Dest.Y+op.Dest.Y), true
+		}
+
+	case Rotate:
+		last, ok := earlier.(Rotate)
+		if ok && last.ID == op.ID && last.Around != nil && op.Around != nil && *last.Around == *op.Around {
+			return NewRotate(op.ID, math.Mod(last.Degrees+op.Degrees, 360), op.Around), true
+		}
+
+	case Scale:
+		last, ok := earlier.(Scale)
+		if ok && last.ID == op.ID && (last.Around == nil) == (op.Around == nil) && (op.Around == nil || *last.Around == *op.Around) {
+			return NewScale(op.ID, last.Factor.X*op.Factor.X, last.Factor.Y*op.Factor.Y, op.Around), true
+		}
+
+	case Transform:
+		if last, ok := earlier.(Transform); ok && last.ID == op.ID {
+			return NewTransform(op.ID, op.Matrix.Mul(last.Matrix)), true
+		}
+	}
+
+	return nil, false
+}
+
+// shapeOf returns the id of the shape the operation is done on, it is zero
+// for the operations done on the whole scene.
+func shapeOf(op Operation) int {
+	switch op := op.(type) {
+	case Move:
+		return op.ID
+	case Rotate:
+		return op.ID
+	case Scale:
+		return op.ID
+	case Transform:
+		return op.ID
+	case Clone:
+		return op.ID
+	}
+
+	return 0
+}
+
+// replayable tells whether the arguments of the operation stay within the
+// limits of its command.
+func replayable(op Operation) bool {
+	within := func(limit float64, values ...float64) bool {
+		for _, v := range values {
+			if math.Abs(v) > limit {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch op := op.(type) {
+	case Move:
+		return within(MaxOffset, op.Dest.X, op.Dest.Y)
+	case Rotate:
+		return within(MaxDegrees, op.Degrees)
+	case Scale:
+		return within(MaxFactor, op.Factor.X, op.Factor.Y)
+	case Transform:
+		m := op.Matrix
+		return within(MaxFactor, m.A, m.B, m.C, m.D) && within(MaxOffset, m.E, m.F) && m.Det() != 0
+	}
+
+	return true
+}
+
+// undo drops the latest operation from the history, or all of the
+// operations of the latest gesture, and builds the scene again from the
+// rest.
+func (l *Loop) undo() {
+	defer l.historyM.Unlock()
+
+	l.historyM.Lock()
+
+	if len(l.history) == 0 {
+		return
+	}
+
+	last := len(l.history) - 1
+
+	for gesture := l.history[last].gesture; gesture != 0 && last > 0 && l.history[last-1].gesture == gesture; {
+		last--
+	}
+
+	l.history = l.history[:last]
+
+	l.Gen.Update(Reset{})
+
+	for _, op := range l.base {
+		l.Gen.Update(op)
+	}
+
+	for _, e := range l.history {
+		l.Gen.Update(e.op)
+	}
+}
+
+// History returns the operations done by the loop in the order they were
+// done.
+func (l *Loop) History() []Operation {
+	defer l.historyM.Unlock()
+
+	l.historyM.Lock()
+
+	history := make([]Operation, len(l.history))
+
+	for i, e := range l.history {
+		history[i] = e.op
+	}
+
+	return history
+}
+
+// QueueLength returns the number of operations posted but not done yet.
+func (l *Loop) QueueLength() int {
+	return l.queue.Len()
+}
+
 func (l *Loop) PostOperation(op Operation) {
 	l.queue.Push(op)
 }
@@ -130,4 +345,17 @@ func (l *Loop) AddDefaultElements() {
 	tf := NewTFigure(0.5, 0.5)
 	l.Gen.Update(bck)
 	l.Gen.Update(tf)
+
+	l.base = append(l.base, bck, tf)
+}
+
+// Undo takes back the latest operation done by the loop.
+type Undo struct{}
+
+func (Undo) String() string {
+	return "undo"
+}
+
+func (u Undo) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
 }
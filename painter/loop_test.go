@@ -141,6 +141,132 @@ func TestLoop_Post(t *testing.T) {
 	l.Terminate()
 }
 
+func TestLoop_History(t *testing.T) {
+	var l Loop
+
+	ops := []Operation{
+		NewTFigure(0.5, 0.5),
+		NewMoveShape(1, 0.1, 0),
+		NewMoveShape(2, 0, 0.1),
+		NewMoveShape(1, 0.1, 0.2),
+		NewMoveShape(1, 0.1, 0),
+	}
+
+	// operations posted on their own are kept apart
+	for _, op := range ops {
+		l.record(op, 0)
+	}
+
+	if history := l.History(); !reflect.DeepEqual(history, ops) {
+		t.Errorf("history is %v, expected %v", history, ops)
+	}
+
+	var drags Loop
+
+	drags.record(NewMoveShape(1, 0.1, 0), 1)
+	drags.record(NewMoveShape(2, 0, 0.1), 1)
+	drags.record(NewMoveShape(1, 0.1, 0.2), 1)
+	drags.record(NewMoveShape(1, 0.1, 0), 2)
+	drags.record(NewMoveShape(0, 0.1, 0), 2)
+	drags.record(NewMoveShape(1, 0.1, 0), 2)
+
+	expected := []Operation{
+		NewMoveShape(1, 0.2, 0.2),
+		NewMoveShape(2, 0, 0.1),
+		NewMoveShape(1, 0.1, 0),
+		NewMoveShape(0, 0.1, 0),
+		NewMoveShape(1, 0.1, 0),
+	}
+
+	if history := drags.History(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("history is %v, expected %v", history, expected)
+	}
+
+	var turns Loop
+	around := Point{X: 0.5, Y: 0.5}
+
+	turns.record(NewRotate(1, 10, &around), 1)
+	turns.record(NewRotate(1, 20, &around), 1)
+	turns.record(NewRotate(1, 30, nil), 1)
+	turns.record(NewRotate(1, 40, &around), 1)
+	turns.record(NewTransform(1, Scaling(Point{X: 2, Y: 2}, around)), 2)
+	turns.record(NewTransform(1, Scaling(Point{X: 1.5, Y: 1}, around)), 2)
+	turns.record(NewScale(1, 2, 3, &around), 3)
+	turns.record(NewScale(1, 2, 0.5, &around), 3)
+
+	expected = []Operation{
+		NewRotate(1, 30, &around),
+		NewRotate(1, 30, nil),
+		NewRotate(1, 40, &around),
+		NewTransform(1, Scaling(Point{X: 3, Y: 2}, around)),
+		NewScale(1, 4, 1.5, &around),
+	}
+
+	if history := turns.History(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("history is %v, expected %v", history, expected)
+	}
+
+	// sums past the limits of the commands are kept apart
+	var far Loop
+
+	far.record(NewMoveShape(1, 0.6, 0), 1)
+	far.record(NewMoveShape(1, 0.6, 0), 1)
+	far.record(NewRotate(1, 200, &around), 2)
+	far.record(NewRotate(1, 200, &around), 2)
+
+	expected = []Operation{
+		NewMoveShape(1, 0.6, 0),
+		NewMoveShape(1, 0.6, 0),
+		NewRotate(1, 40, &around),
+	}
+
+	if history := far.History(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("history is %v, expected %v", history, expected)
+	}
+}
+
+func TestLoop_Undo(t *testing.T) {
+	gen := Generator{}
+	l := Loop{Gen: &gen}
+	l.AddDefaultElements()
+
+	ops := []Gesture{
+		{Operation: NewTFigure(0.2, 0.2)},
+		{Operation: NewMoveShape(2, 0.1, 0)},
+		{Operation: NewMoveShape(2, 0.1, 0), ID: 1},
+		{Operation: NewMoveShape(1, 0.1, 0), ID: 1},
+		{Operation: NewMoveShape(2, 0.1, 0.1), ID: 1},
+	}
+
+	for _, g := range ops {
+		gen.Update(g)
+		l.record(g.Operation, g.ID)
+	}
+
+	if tfs := gen.GetTFigures(); len(tfs) != 2 || !near(tfs[1].Center, Point{0.5, 0.3}) || !near(tfs[0].Center, Point{0.6, 0.5}) {
+		t.Fatalf("the figures have to be moved before the undo")
+	}
+
+	l.undo()
+
+	if tfs := gen.GetTFigures(); len(tfs) != 2 || !near(tfs[1].Center, Point{0.3, 0.2}) || !near(tfs[0].Center, Point{0.5, 0.5}) {
+		t.Errorf("undo has to take back the whole drag and only it")
+	}
+
+	l.undo()
+
+	if tfs := gen.GetTFigures(); len(tfs) != 2 || !near(tfs[1].Center, Point{0.2, 0.2}) {
+		t.Errorf("undo has to take back the move posted on its own")
+	}
+
+	l.undo()
+	l.undo()
+
+	if tfs := gen.GetTFigures(); len(tfs) != 1 || len(l.History()) != 0 {
+		t.Errorf("undo has to keep the default elements, got %d figures", len(tfs))
+	}
+}
+
 type LogOperation struct {
 	Data string
 }
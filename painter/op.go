@@ -1,9 +1,10 @@
 package painter
 
-import "image"
 import "fmt"
 import "golang.org/x/exp/shiny/screen"
 import "image/color"
+import "math"
+import "strconv"
 
 type Operation interface{}
 
@@ -17,20 +18,83 @@ type Rectangle struct {
 	Max Point
 }
 
-func convertPointToImagePoint(p Point, size image.Rectangle) image.Point {
-	pointInImage := image.Point{
-		X: size.Min.X + int(float64(size.Max.X-size.Min.X)*p.X),
-		Y: size.Min.Y + int(float64(size.Max.Y-size.Min.Y)*p.Y),
+func (r Rectangle) Contains(p Point) bool {
+	return r.Min.X <= p.X && p.X < r.Max.X && r.Min.Y <= p.Y && p.Y < r.Max.Y
+}
+
+// Union returns the smallest rectangle containing both r and s.
+func (r Rectangle) Union(s Rectangle) Rectangle {
+	return Rectangle{
+		Min: Point{X: math.Min(r.Min.X, s.Min.X), Y: math.Min(r.Min.Y, s.Min.Y)},
+		Max: Point{X: math.Max(r.Max.X, s.Max.X), Y: math.Max(r.Max.Y, s.Max.Y)},
+	}
+}
+
+// Center returns the middle of r.
+func (r Rectangle) Center() Point {
+	return Point{X: (r.Min.X + r.Max.X) / 2, Y: (r.Min.Y + r.Max.Y) / 2}
+}
+
+// In tells whether r lies inside of s.
+func (r Rectangle) In(s Rectangle) bool {
+	return s.Min.X <= r.Min.X && r.Max.X <= s.Max.X && s.Min.Y <= r.Min.Y && r.Max.Y <= s.Max.Y
+}
+
+// Grow returns r with every side moved outwards by d.
+func (r Rectangle) Grow(d float64) Rectangle {
+	return Rectangle{
+		Min: Point{X: r.Min.X - d, Y: r.Min.Y - d},
+		Max: Point{X: r.Max.X + d, Y: r.Max.Y + d},
 	}
-	return pointInImage
+}
+
+// Add returns r moved by v.
+func (r Rectangle) Add(v Point) Rectangle {
+	return Rectangle{
+		Min: Point{X: r.Min.X + v.X, Y: r.Min.Y + v.Y},
+		Max: Point{X: r.Max.X + v.X, Y: r.Max.Y + v.Y},
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatColor(c color.RGBA) string {
+	if c.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
 }
 
 type Fill struct {
 	Color color.RGBA
 }
 
-func (f *Fill) Draw(t screen.Texture) {
-	t.Fill(t.Bounds(), f.Color, screen.Src)
+func (f Fill) String() string {
+	switch f.Color {
+	case NewWhiteFill().Color:
+		return "white"
+	case NewGreenFill().Color:
+		return "green"
+	}
+
+	return "fill " + formatColor(f.Color)
+}
+
+func (f Fill) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *Fill) Draw(c Canvas, vp Viewport) {
+	c.Fill(c.Bounds(), f.Color, screen.Src)
+}
+
+// NewFill paints the background with the color, named colors have their own
+// commands.
+func NewFill(c color.RGBA) Fill {
+	return Fill{c}
 }
 
 func NewGreenFill() Fill {
@@ -42,113 +106,234 @@ func NewWhiteFill() Fill {
 }
 
 type TFigure struct {
-	Color               color.RGBA
-	Center              Point
-	originalTextureRect *image.Rectangle
-	Size                Point
+	ID        int
+	Color     color.RGBA
+	Center    Point
+	Size      Point
+	Transform Affine
+
+	// Gradient paints the figure instead of the Color when it is set.
+	Gradient *Gradient
+
+	Stroke Stroke
 }
 
 var TFigureColor = color.RGBA{255, 102, 102, 255}
 
-func (tf *TFigure) getRectangles() (image.Rectangle, image.Rectangle, error) {
-	if tf.originalTextureRect == nil {
-		return image.Rectangle{}, image.Rectangle{}, fmt.Errorf("Forget add texture to original rectangle...")
-	}
+var TFigureSize = Point{0.25, 0.25}
 
-	horizontal := *tf.originalTextureRect
-	vertical := *tf.originalTextureRect
+// Proportions of the bars relative to the size of the figure.
+const (
+	tfigureBarHeight = 0.5
+	tfigureStemWidth = 0.5
+)
 
-	centerInTexture := convertPointToImagePoint(tf.Center, horizontal)
+func (tf TFigure) String() string {
+	s := "figure " + formatFloat(tf.Center.X) + " " + formatFloat(tf.Center.Y)
 
-	sizeInTexture := image.Point{
-		X: int(float64(horizontal.Max.X-horizontal.Min.X) * tf.Size.X),
-		Y: int(float64(horizontal.Max.Y-horizontal.Min.Y) * tf.Size.Y),
+	if tf.Size != TFigureSize {
+		s += " " + formatFloat(tf.Size.X) + " " + formatFloat(tf.Size.Y)
 	}
 
-	horizontal.Min.Y = centerInTexture.Y - int(float64(sizeInTexture.Y)*0.5)
-	horizontal.Max.Y = centerInTexture.Y
-	horizontal.Min.X = centerInTexture.X - int(float64(sizeInTexture.X)*0.5)
-	horizontal.Max.X = centerInTexture.X + int(float64(sizeInTexture.X)*0.5)
+	if tf.Color != TFigureColor {
+		s += " " + formatColor(tf.Color)
+	}
+
+	return s
+}
+
+func (tf TFigure) MarshalText() ([]byte, error) {
+	return []byte(tf.String()), nil
+}
+
+// getRectangles returns the bars of the figure around the origin, before
+// they are transformed and placed at the center.
+func (tf *TFigure) getRectangles() (horizontal Rectangle, vertical Rectangle) {
+	halfSize := Point{X: tf.Size.X * 0.5, Y: tf.Size.Y * 0.5}
+
+	horizontal.Min.Y = -halfSize.Y
+	horizontal.Max.Y = horizontal.Min.Y + tf.Size.Y*tfigureBarHeight
+	horizontal.Min.X = -halfSize.X
+	horizontal.Max.X = halfSize.X
 
 	vertical.Min.Y = horizontal.Min.Y
-	vertical.Max.Y = centerInTexture.Y + int(float64(sizeInTexture.Y)*0.5)
-	vertical.Max.X = centerInTexture.X + int(float64(sizeInTexture.X)*0.25)
-	vertical.Min.X = centerInTexture.X - int(float64(sizeInTexture.X)*0.25)
+	vertical.Max.Y = halfSize.Y
+	vertical.Max.X = halfSize.X * tfigureStemWidth
+	vertical.Min.X = -halfSize.X * tfigureStemWidth
 
-	return horizontal, vertical, nil
+	return
 }
 
-func (tf *TFigure) Contains(p image.Point) bool {
-	horizontal, vertical, err := tf.getRectangles()
+// placement maps the coordinates the bars are given in to the canvas.
+func (tf *TFigure) placement() Affine {
+	return Translation(tf.Center).Mul(tf.Transform)
+}
 
-	if err != nil {
-		return false
+// outline returns the border of the figure on the canvas.
+func (tf *TFigure) outline() []Point {
+	horizontal, vertical := tf.getRectangles()
+	m := tf.placement()
+
+	outline := []Point{
+		horizontal.Min,
+		{X: horizontal.Max.X, Y: horizontal.Min.Y},
+		{X: horizontal.Max.X, Y: horizontal.Max.Y},
+		{X: vertical.Max.X, Y: horizontal.Max.Y},
+		vertical.Max,
+		{X: vertical.Min.X, Y: vertical.Max.Y},
+		{X: vertical.Min.X, Y: horizontal.Max.Y},
+		{X: horizontal.Min.X, Y: horizontal.Max.Y},
 	}
 
-	return p.In(horizontal) || p.In(vertical)
+	for i, p := range outline {
+		outline[i] = m.Apply(p)
+	}
+
+	return outline
 }
 
-func (tf *TFigure) Move(v image.Point) {
-	if tf.originalTextureRect == nil {
-		return
+func (tf *TFigure) polygons() [][]Point {
+	horizontal, vertical := tf.getRectangles()
+	m := tf.placement()
+
+	polygons := [][]Point{horizontal.Polygon(), vertical.Polygon()}
+
+	for _, polygon := range polygons {
+		for i, p := range polygon {
+			polygon[i] = m.Apply(p)
+		}
 	}
 
-	tf.originalTextureRect.Min.X += v.X
-	tf.originalTextureRect.Min.Y += v.Y
+	return polygons
+}
+
+// Bounds returns the smallest rectangle containing the figure.
+func (tf *TFigure) Bounds() Rectangle {
+	polygons := tf.polygons()
+	bounds := Rectangle{Min: polygons[0][0], Max: polygons[0][0]}
 
-	tf.originalTextureRect.Max.X += v.X
-	tf.originalTextureRect.Max.Y += v.Y
+	for _, polygon := range polygons {
+		for _, p := range polygon {
+			bounds = bounds.Union(Rectangle{Min: p, Max: p})
+		}
+	}
+
+	return bounds
 }
 
-func (tf *TFigure) Draw(t screen.Texture) {
-	if tf.originalTextureRect == nil {
-		fullRect := t.Bounds()
-		tf.originalTextureRect = &fullRect
+func (tf *TFigure) Contains(p Point) bool {
+	inv, ok := tf.placement().Invert()
+
+	if !ok {
+		return false
 	}
 
-	horizontal, vertical, _ := tf.getRectangles()
+	p = inv.Apply(p)
+	horizontal, vertical := tf.getRectangles()
+
+	return horizontal.Contains(p) || vertical.Contains(p)
+}
+
+func (tf *TFigure) Move(v Point) {
+	tf.Center.X += v.X
+	tf.Center.Y += v.Y
+}
 
-	t.Fill(horizontal, tf.Color, screen.Src)
-	t.Fill(vertical, tf.Color, screen.Src)
+// Apply transforms the figure by m given in canvas coordinates.
+func (tf *TFigure) Apply(m Affine) {
+	tf.Center = m.Apply(tf.Center)
+	tf.Transform = m.Linear().Mul(tf.Transform)
+}
+
+func (tf *TFigure) Draw(c Canvas, vp Viewport) {
+	if tf.Gradient != nil {
+		paintPolygons(c, vp, tf.polygons(), tf.Gradient, pixelBounds(vp, tf.Bounds()))
+	} else {
+		fillPolygons(c, vp, tf.polygons(), tf.Color, screen.Src)
+	}
+
+	tf.Stroke.Draw(c, vp, tf.outline(), true)
+}
+
+func (tf *TFigure) Resize(size Point) {
+	tf.Size = size
 }
 
 func NewTFigure(x, y float64) TFigure {
+	return NewCustomTFigure(x, y, TFigureSize.X, TFigureSize.Y, TFigureColor)
+}
+
+func NewCustomTFigure(x, y, w, h float64, c color.RGBA) TFigure {
 	center := Point{x, y}
 	return TFigure{
-		Color:               TFigureColor,
-		Center:              center,
-		originalTextureRect: nil,
-		Size:                Point{0.25, 0.25}, // default size
+		Color:     c,
+		Center:    center,
+		Size:      Point{w, h},
+		Transform: Identity(),
 	}
 }
 
+type Resize struct {
+	ID   int
+	Size Point
+}
+
+func (r Resize) String() string {
+	return "resize " + strconv.Itoa(r.ID) + " " + formatFloat(r.Size.X) + " " + formatFloat(r.Size.Y)
+}
+
+func (r Resize) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func NewResize(id int, w, h float64) Resize {
+	return Resize{ID: id, Size: Point{X: w, Y: h}}
+}
+
 type BRect struct {
-	originalTextureRect *image.Rectangle
-	Bounds              Rectangle
+	ID   int
+	Rect Rectangle
+
+	// Gradient paints the rectangle instead of black when it is set.
+	Gradient *Gradient
+
+	Stroke Stroke
 }
 
-func (brect *BRect) getRectToFill() (image.Rectangle, error) {
-	if brect.originalTextureRect == nil {
-		return image.Rectangle{}, fmt.Errorf("forget add texture to brect.originalTextureRect")
-	}
+func (brect BRect) String() string {
+	return "brect " +
+		formatFloat(brect.Rect.Min.X) + " " + formatFloat(brect.Rect.Min.Y) + " " +
+		formatFloat(brect.Rect.Max.X) + " " + formatFloat(brect.Rect.Max.Y)
+}
 
-	var rectToFill image.Rectangle
+func (brect BRect) MarshalText() ([]byte, error) {
+	return []byte(brect.String()), nil
+}
 
-	rectToFill.Min = convertPointToImagePoint(brect.Bounds.Min, *brect.originalTextureRect)
-	rectToFill.Max = convertPointToImagePoint(brect.Bounds.Max, *brect.originalTextureRect)
+func (brect *BRect) Bounds() Rectangle {
+	return brect.Rect
+}
 
-	return rectToFill, nil
+func (brect *BRect) Contains(p Point) bool {
+	return brect.Rect.Contains(p)
 }
 
-func (brect *BRect) Draw(t screen.Texture) {
-	if brect.originalTextureRect == nil {
-		fullRect := t.Bounds()
-		brect.originalTextureRect = &fullRect
-	}
+func (brect *BRect) Move(v Point) {
+	brect.Rect.Min.X += v.X
+	brect.Rect.Min.Y += v.Y
+	brect.Rect.Max.X += v.X
+	brect.Rect.Max.Y += v.Y
+}
 
-	rectToFill, _ := brect.getRectToFill()
+// MoveTo puts the top-left corner of the rectangle at p.
+func (brect *BRect) Draw(c Canvas, vp Viewport) {
+	if brect.Gradient != nil {
+		paintPolygons(c, vp, [][]Point{brect.Rect.Polygon()}, brect.Gradient, pixelBounds(vp, brect.Rect))
+	} else {
+		fillPolygons(c, vp, [][]Point{brect.Rect.Polygon()}, color.RGBA{A: 0xff}, screen.Src)
+	}
 
-	t.Fill(rectToFill, color.RGBA{A: 0xff}, screen.Src)
+	brect.Stroke.Draw(c, vp, brect.Rect.Polygon(), true)
 }
 
 func NewBRect(x1, y1, x2, y2 float64) BRect {
@@ -173,62 +358,238 @@ func NewBRect(x1, y1, x2, y2 float64) BRect {
 	bounds := Rectangle{Min: topLeft, Max: botRight}
 
 	return BRect{
-		originalTextureRect: nil,
-		Bounds:              bounds,
+		Rect: bounds,
 	}
 }
 
+// Move shifts the shapes by Dest, all of them or only the shape with the
+// ID together with its group when the ID is given.
 type Move struct {
-	Dest                Point
-	originalTextureRect *image.Rectangle
-	Range               []*TFigure
+	ID    int
+	Dest  Point
+	Range []Shape
 }
 
-func (mv *Move) SetRange(tfs []*TFigure) {
-	mv.Range = make([]*TFigure, len(tfs))
-	copy(mv.Range, tfs)
+func (mv Move) String() string {
+	s := "move "
+
+	if mv.ID != 0 {
+		s += strconv.Itoa(mv.ID) + " "
+	}
+
+	return s + formatFloat(mv.Dest.X) + " " + formatFloat(mv.Dest.Y)
 }
 
-func (mv *Move) Move(t screen.Texture) {
-	tfs := mv.Range
+func (mv Move) MarshalText() ([]byte, error) {
+	return []byte(mv.String()), nil
+}
 
-	if mv.originalTextureRect == nil {
-		fullRect := t.Bounds()
-		mv.originalTextureRect = &fullRect
+func (mv *Move) SetRange(shapes []Shape) {
+	mv.Range = make([]Shape, len(shapes))
+	copy(mv.Range, shapes)
+}
+
+func (mv *Move) Move() {
+	for _, sh := range mv.Range {
+		sh.Move(mv.Dest)
 	}
+}
 
-	realDest := convertPointToImagePoint(mv.Dest, *mv.originalTextureRect)
+func NewMove(x, y float64) Move {
+	dest := Point{X: x, Y: y}
+	return Move{Dest: dest}
+}
+
+func NewMoveShape(id int, x, y float64) Move {
+	mv := NewMove(x, y)
+	mv.ID = id
+	return mv
+}
+
+// MoveTo puts the middle of the bounds of every shape on Dest, or of the
+// shape with the ID together with its group.
+type MoveTo struct {
+	ID    int
+	Dest  Point
+	Range []Shape
+}
 
-	for _, tf := range tfs {
-		tf.Move(realDest)
+func (mv MoveTo) String() string {
+	s := "moveto "
+
+	if mv.ID != 0 {
+		s += strconv.Itoa(mv.ID) + " "
 	}
+
+	return s + formatFloat(mv.Dest.X) + " " + formatFloat(mv.Dest.Y)
 }
 
-func NewMove(x, y float64) Move {
+func (mv MoveTo) MarshalText() ([]byte, error) {
+	return []byte(mv.String()), nil
+}
+
+func (mv *MoveTo) SetRange(shapes []Shape) {
+	mv.Range = make([]Shape, len(shapes))
+	copy(mv.Range, shapes)
+}
+
+func (mv *MoveTo) Move() {
+	if len(mv.Range) == 0 {
+		return
+	}
+
+	offset := func(bounds Rectangle) Point {
+		c := bounds.Center()
+		return Point{X: mv.Dest.X - c.X, Y: mv.Dest.Y - c.Y}
+	}
+
+	// a group keeps its shape, the middle of all of its bounds is moved
+	if mv.ID != 0 {
+		v := offset(boundsOf(mv.Range))
+
+		for _, sh := range mv.Range {
+			sh.Move(v)
+		}
+		return
+	}
+
+	for _, sh := range mv.Range {
+		sh.Move(offset(sh.Bounds()))
+	}
+}
+
+func NewMoveTo(x, y float64) MoveTo {
 	dest := Point{X: x, Y: y}
-	return Move{Dest: dest, originalTextureRect: nil}
+	return MoveTo{Dest: dest}
+}
+
+func NewMoveShapeTo(id int, x, y float64) MoveTo {
+	mv := NewMoveTo(x, y)
+	mv.ID = id
+	return mv
 }
 
 type Reset struct{}
 
+func (Reset) String() string {
+	return "reset"
+}
+
+func (r Reset) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
 type FillCreateFn func() Fill
 
-type CreateTFigureFn func(x, y float64) TFigure
+type CreateFill func(c color.RGBA) Fill
+
+type CreateTFigureFn func(x, y, w, h float64, c color.RGBA) TFigure
 
 type UpdatePoint struct{}
 
+func (UpdatePoint) String() string {
+	return "update"
+}
+
+func (up UpdatePoint) MarshalText() ([]byte, error) {
+	return []byte(up.String()), nil
+}
+
 type CreateBRect func(x1, y1, x2, y2 float64) BRect
 
 type CreateMove func(x, y float64) Move
 
+type CreateMoveTo func(x, y float64) MoveTo
+
+type CreateZoom func(factor float64, at *Point) Zoom
+
+type CreatePan func(x, y float64) Pan
+
+type CreateRotate func(id int, degrees float64, around *Point) Rotate
+
+type CreateScale func(id int, sx, sy float64, around *Point) Scale
+
+type CreateTransform func(id int, m Affine) Transform
+
+type CreateResize func(id int, w, h float64) Resize
+
+type CreateText func(x, y float64, label string, size float64, c color.RGBA) Text
+
+type CreatePicture func(asset string, x, y, w, h float64) Picture
+
+type CreateGradient func(id int, g Gradient) GradientFill
+
+type CreateSetStroke func(id int, width float64, dash []float64, c color.RGBA) SetStroke
+
+type CreateLine func(x1, y1, x2, y2, width float64, dash []float64, c color.RGBA) Line
+
+type CreateLayer func(name string, z *int) Layer
+
+type CreateShowLayer func(name string) ShowLayer
+
+type CreateHideLayer func(name string) HideLayer
+
+type CreateLayerOpacity func(name string, opacity float64) LayerOpacity
+
+type CreateBringToFront func(id int) BringToFront
+
+type CreateSendToBack func(id int) SendToBack
+
+type CreateGroup func(name string, ids []int, groups []string) Group
+
+type CreateUngroup func(name string) Ungroup
+
+type CreateDelete func(id int) Delete
+
+type CreateSetGrid func(spacing float64) SetGrid
+
+type CreateClone func(id int, dx, dy float64) Clone
+
+type CreateAlign func(edge AlignEdge, ids []int) Align
+
+type CreateDistribute func(axis Axis, ids []int) Distribute
+
 var Table = map[string]Operation{
 	"white":  FillCreateFn(NewWhiteFill),
 	"green":  FillCreateFn(NewGreenFill),
-	"figure": CreateTFigureFn(NewTFigure),
+	"fill":   CreateFill(NewFill),
+	"figure": CreateTFigureFn(NewCustomTFigure),
 	"update": UpdatePoint{},
 	"brect":  CreateBRect(NewBRect),
 	"move":   CreateMove(NewMove),
+	"moveto": CreateMoveTo(NewMoveTo),
 	"reset":  Reset{},
+	"zoom":   CreateZoom(NewZoom),
+	"pan":    CreatePan(NewPan),
+	"fit":    Fit{},
+	"undo":   Undo{},
+
+	"rotate":    CreateRotate(NewRotate),
+	"scale":     CreateScale(NewScale),
+	"transform": CreateTransform(NewTransform),
+	"resize":    CreateResize(NewResize),
+	"text":      CreateText(NewText),
+	"image":     CreatePicture(NewPicture),
+	"gradient":  CreateGradient(NewGradientFill),
+	"stroke":    CreateSetStroke(NewSetStroke),
+	"line":      CreateLine(NewLine),
+
+	"layer":          CreateLayer(NewLayer),
+	"show":           CreateShowLayer(NewShowLayer),
+	"hide":           CreateHideLayer(NewHideLayer),
+	"opacity":        CreateLayerOpacity(NewLayerOpacity),
+	"bring-to-front": CreateBringToFront(NewBringToFront),
+	"send-to-back":   CreateSendToBack(NewSendToBack),
+	"group":          CreateGroup(NewGroup),
+	"ungroup":        CreateUngroup(NewUngroup),
+	"delete":         CreateDelete(NewDelete),
+
+	"grid": CreateSetGrid(NewSetGrid),
+	"snap": ToggleSnap{},
+
+	"clone":      CreateClone(NewClone),
+	"align":      CreateAlign(NewAlign),
+	"distribute": CreateDistribute(NewDistribute),
 }
 
 func GetTable() map[string]Operation {
@@ -0,0 +1,156 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"unicode"
+
+	"golang.org/x/mobile/event/key"
+)
+
+var (
+	// PaletteColor is premultiplied by its alpha.
+	PaletteColor      = color.RGBA{R: 0x10, G: 0x10, B: 0x10, A: 0xd0}
+	PaletteTextColor  = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	PaletteErrorColor = color.RGBA{R: 0xff, G: 0x66, B: 0x66, A: 0xff}
+)
+
+// Sizes of the palette in pixels.
+const (
+	paletteLine    = 26
+	paletteText    = 18
+	palettePadding = 6
+)
+
+// Palette is a line at the bottom of the window where commands of the
+// painter are typed, Enter runs them and Escape closes the palette.
+type Palette struct {
+	open  bool
+	input []rune
+
+	// message tells why the last command was not run.
+	message string
+
+	// recent holds the commands run before, Up and Down bring them back.
+	recent []string
+	recall int
+
+	// Parse turns the typed line into operations.
+	Parse          func(command string) ([]Operation, error)
+	PostOperations func(ops []Operation)
+}
+
+func (p *Palette) Open() {
+	p.open = true
+	p.input = p.input[:0]
+	p.message = ""
+	p.recall = len(p.recent)
+}
+
+func (p *Palette) Close() {
+	p.open = false
+}
+
+func (p *Palette) IsOpen() bool {
+	return p.open
+}
+
+// run parses the typed line and posts its operations, the palette closes
+// unless the line has a mistake.
+func (p *Palette) run() {
+	command := string(p.input)
+
+	if command == "" {
+		p.Close()
+		return
+	}
+
+	ops, err := p.Parse(command)
+
+	if err != nil {
+		p.message = err.Error()
+		return
+	}
+
+	p.PostOperations(ops)
+	p.recent = append(p.recent, command)
+	p.Close()
+}
+
+// HandleKey edits the line and tells whether the window has to be redrawn.
+// Every key goes to the open palette.
+func (p *Palette) HandleKey(e key.Event) bool {
+	if e.Direction == key.DirRelease {
+		return false
+	}
+
+	switch e.Code {
+	case key.CodeEscape:
+		p.Close()
+
+	case key.CodeReturnEnter, key.CodeKeypadEnter:
+		p.run()
+
+	case key.CodeDeleteBackspace:
+		if len(p.input) > 0 {
+			p.input = p.input[:len(p.input)-1]
+		}
+
+	case key.CodeUpArrow, key.CodeDownArrow:
+		if e.Code == key.CodeUpArrow {
+			p.recall = max(p.recall-1, 0)
+		} else {
+			p.recall = min(p.recall+1, len(p.recent))
+		}
+
+		p.input = p.input[:0]
+		if p.recall < len(p.recent) {
+			p.input = []rune(p.recent[p.recall])
+		}
+
+	default:
+		if e.Modifiers&(key.ModControl|key.ModAlt|key.ModMeta) != 0 || !unicode.IsPrint(e.Rune) {
+			return false
+		}
+
+		p.input = append(p.input, e.Rune)
+	}
+
+	return true
+}
+
+// Draw shows the typed line at the bottom of the canvas with the mistake of
+// the last command above it. The palette stays in place when the camera
+// moves, so it is drawn in pixels.
+func (p *Palette) Draw(c Canvas, vp Viewport) {
+	if !p.open {
+		return
+	}
+
+	px := NewViewport(image.Rect(0, 0, 1, 1))
+	bounds := c.Bounds()
+
+	lines := 1
+	if p.message != "" {
+		lines++
+	}
+
+	bar := Rectangle{
+		Min: Point{X: float64(bounds.Min.X), Y: float64(bounds.Max.Y - lines*paletteLine)},
+		Max: Point{X: float64(bounds.Max.X), Y: float64(bounds.Max.Y)},
+	}
+
+	fillPolygons(c, px, [][]Point{bar.Polygon()}, PaletteColor, draw.Over)
+
+	at := Point{X: bar.Min.X + palettePadding, Y: bar.Min.Y + (paletteLine-paletteText)/2}
+
+	if p.message != "" {
+		message := NewText(at.X, at.Y, p.message, paletteText, PaletteErrorColor)
+		message.Draw(c, px)
+		at.Y += paletteLine
+	}
+
+	line := NewText(at.X, at.Y, "> "+string(p.input)+"_", paletteText, PaletteTextColor)
+	line.Draw(c, px)
+}
@@ -0,0 +1,402 @@
+package painter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+var missingAssetColor = color.RGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}
+
+// Sprites bigger than this amount of pixels are not kept in the cache,
+// which happens mostly when a picture is zoomed in.
+const maxCachedSprite = 2048 * 2048
+
+// Assets bigger than this amount of pixels are not decoded.
+const maxAssetPixels = 4096 * 4096
+
+// spritesPerAsset limits the sizes an asset is kept scaled to, the sprite
+// used the longest time ago is dropped first.
+const spritesPerAsset = 4
+
+// Picture shows an asset stretched over a rectangle with the top-left
+// corner at Position.
+type Picture struct {
+	ID       int
+	Asset    string
+	Position Point
+	Size     Point
+	Stroke   Stroke
+
+	assets *assetStore
+}
+
+func (pic Picture) String() string {
+	return "image " + pic.Asset + " " +
+		formatFloat(pic.Position.X) + " " + formatFloat(pic.Position.Y) + " " +
+		formatFloat(pic.Size.X) + " " + formatFloat(pic.Size.Y)
+}
+
+func (pic Picture) MarshalText() ([]byte, error) {
+	return []byte(pic.String()), nil
+}
+
+func (pic *Picture) GetID() int {
+	return pic.ID
+}
+
+func (pic *Picture) setID(id int) {
+	pic.ID = id
+}
+
+func (pic *Picture) clone() Shape {
+	c := *pic
+	return &c
+}
+
+func (pic *Picture) setStroke(s Stroke) {
+	pic.Stroke = s
+}
+
+func (pic *Picture) Bounds() Rectangle {
+	return Rectangle{
+		Min: pic.Position,
+		Max: Point{X: pic.Position.X + pic.Size.X, Y: pic.Position.Y + pic.Size.Y},
+	}
+}
+
+func (pic *Picture) Contains(p Point) bool {
+	return pic.Bounds().Contains(p)
+}
+
+func (pic *Picture) Move(v Point) {
+	pic.Position.X += v.X
+	pic.Position.Y += v.Y
+}
+
+func (pic *Picture) Draw(c Canvas, vp Viewport) {
+	dr := vp.ToImageRect(pic.Bounds())
+
+	if !dr.Intersect(c.Bounds()).Empty() && (pic.assets == nil || !pic.assets.draw(c, pic.Asset, dr)) {
+		fill(c, dr, missingAssetColor, screen.Src)
+	}
+
+	pic.Stroke.Draw(c, vp, pic.Bounds().Polygon(), true)
+}
+
+func NewPicture(asset string, x, y, w, h float64) Picture {
+	return Picture{
+		Asset:    asset,
+		Position: Point{X: x, Y: y},
+		Size:     Point{X: w, Y: h},
+	}
+}
+
+// ValidAssetName tells whether the name can be used for an asset, names are
+// plain file names inside of the asset directory.
+func ValidAssetName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`) &&
+		!strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' || r == '"' || r == '&' })
+}
+
+// sprite is an asset scaled to the size it is drawn at.
+type sprite struct {
+	img *image.RGBA
+
+	// opaque covers the pixels of img that are drawn on canvases unable
+	// to blend.
+	opaque []image.Rectangle
+
+	// buffer holds img uploaded for the screen.
+	buffer screen.Buffer
+}
+
+func newSprite(src image.Image, size image.Point, visible image.Rectangle) *sprite {
+	img := image.NewRGBA(visible)
+	draw.ApproxBiLinear.Scale(img, image.Rectangle{Max: size}, src, src.Bounds(), draw.Src, nil)
+
+	sp := &sprite{img: img}
+
+	for y := visible.Min.Y; y < visible.Max.Y; y++ {
+		start := -1
+
+		for x := visible.Min.X; x <= visible.Max.X; x++ {
+			covered := x < visible.Max.X && img.RGBAAt(x, y).A >= 0x80
+
+			if covered && start < 0 {
+				start = x
+			} else if !covered && start >= 0 {
+				span := image.Rect(start, y, x, y+1)
+
+				// rows covered the same way are joined together
+				if last := len(sp.opaque) - 1; last >= 0 && sp.opaque[last].Max.Y == y &&
+					sp.opaque[last].Min.X == span.Min.X && sp.opaque[last].Max.X == span.Max.X {
+					sp.opaque[last].Max.Y++
+				} else {
+					sp.opaque = append(sp.opaque, span)
+				}
+
+				start = -1
+			}
+		}
+	}
+
+	return sp
+}
+
+func (sp *sprite) release() {
+	if sp.buffer != nil {
+		sp.buffer.Release()
+		sp.buffer = nil
+	}
+}
+
+// drawSprite copies the sprite to the canvas with its origin at dp.
+func drawSprite(c Canvas, sp *sprite, dp image.Point) {
+	switch c := c.(type) {
+	case ImageCanvas:
+		dr := sp.img.Bounds().Add(dp)
+		draw.Draw(c.RGBA, dr, sp.img, sp.img.Bounds().Min, draw.Over)
+
+	case textureCanvas:
+		if sp.buffer == nil {
+			buffer, err := c.scr.NewBuffer(sp.img.Bounds().Size())
+
+			if err != nil {
+				log.Printf("cannot upload an image: %s", err)
+				return
+			}
+
+			draw.Draw(buffer.RGBA(), buffer.Bounds(), sp.img, sp.img.Bounds().Min, draw.Src)
+			sp.buffer = buffer
+		}
+
+		origin := sp.img.Bounds().Min
+
+		for _, r := range sp.opaque {
+			dr := r.Add(dp).Intersect(c.Bounds())
+
+			if !dr.Empty() {
+				c.Upload(dr.Min, sp.buffer, dr.Sub(dp).Sub(origin))
+			}
+		}
+
+	default:
+		for _, r := range sp.opaque {
+			fill(c, r.Add(dp), missingAssetColor, screen.Src)
+		}
+	}
+}
+
+// asset is the decoded image with the sprites it is scaled into by their
+// sizes, or the error of loading it.
+type asset struct {
+	img image.Image
+	err error
+
+	sprites map[image.Point]*sprite
+
+	// used holds the sizes of the sprites, the latest used is the last.
+	used []image.Point
+}
+
+// sprite returns the asset scaled to the size, pictures of different sizes
+// showing the same asset keep sprites of their own.
+func (a *asset) sprite(size image.Point) *sprite {
+	for i, s := range a.used {
+		if s == size {
+			a.used = append(append(a.used[:i:i], a.used[i+1:]...), size)
+			return a.sprites[size]
+		}
+	}
+
+	if len(a.used) == spritesPerAsset {
+		a.sprites[a.used[0]].release()
+		delete(a.sprites, a.used[0])
+		a.used = a.used[1:]
+	}
+
+	if a.sprites == nil {
+		a.sprites = map[image.Point]*sprite{}
+	}
+
+	sp := newSprite(a.img, size, image.Rectangle{Max: size})
+	a.sprites[size] = sp
+	a.used = append(a.used, size)
+
+	return sp
+}
+
+// release frees the buffers of all of the sprites.
+func (a *asset) release() {
+	for _, sp := range a.sprites {
+		sp.release()
+	}
+}
+
+// assetStore keeps the decoded assets together with their sprites, so
+// pictures are neither decoded nor scaled every frame.
+type assetStore struct {
+	m sync.Mutex
+
+	dir    string
+	assets map[string]*asset
+}
+
+// decodeAsset decodes a PNG or JPEG image unless it has too many pixels.
+func decodeAsset(in io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(in)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxAssetPixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too big", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+
+	return img, err
+}
+
+// get returns the asset, loading it from the directory when it is not
+// uploaded yet. Assets failed to load are remembered, so they are not
+// opened every frame. The store has to be locked by the caller.
+func (as *assetStore) get(name string) (*asset, error) {
+	if a, ok := as.assets[name]; ok {
+		return a, a.err
+	}
+
+	if as.dir == "" || !ValidAssetName(name) {
+		return nil, fmt.Errorf("no asset `%s`", name)
+	}
+
+	img, err := as.load(name)
+
+	if err != nil {
+		log.Printf("asset `%s`: %s", name, err)
+		as.store(name, &asset{err: err})
+		return nil, err
+	}
+
+	as.put(name, img)
+
+	return as.assets[name], nil
+}
+
+// load decodes the asset from the directory.
+func (as *assetStore) load(name string) (image.Image, error) {
+	f, err := os.Open(filepath.Join(as.dir, name))
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return decodeAsset(f)
+}
+
+// put stores the asset, the store has to be locked by the caller.
+func (as *assetStore) put(name string, img image.Image) {
+	as.store(name, &asset{img: img})
+}
+
+// store replaces the asset with the name releasing the sprites of the old
+// one, the store has to be locked by the caller.
+func (as *assetStore) store(name string, a *asset) {
+	if old, ok := as.assets[name]; ok {
+		old.release()
+	}
+
+	if as.assets == nil {
+		as.assets = map[string]*asset{}
+	}
+
+	as.assets[name] = a
+}
+
+// draw stretches the asset over dr and tells whether the asset is found.
+func (as *assetStore) draw(c Canvas, name string, dr image.Rectangle) bool {
+	defer as.m.Unlock()
+
+	as.m.Lock()
+
+	a, err := as.get(name)
+
+	if err != nil {
+		return false
+	}
+
+	size := dr.Size()
+
+	if size.X*size.Y > maxCachedSprite {
+		visible := dr.Intersect(c.Bounds()).Sub(dr.Min)
+		sp := newSprite(a.img, size, visible)
+		defer sp.release()
+
+		drawSprite(c, sp, dr.Min)
+		return true
+	}
+
+	drawSprite(c, a.sprite(size), dr.Min)
+
+	return true
+}
+
+// SetAssetDir sets the directory assets are loaded from.
+func (gn *Generator) SetAssetDir(dir string) {
+	defer gn.assets.m.Unlock()
+
+	gn.assets.m.Lock()
+
+	gn.assets.dir = dir
+
+	// assets missing from the old directory can be in the new one
+	for name, a := range gn.assets.assets {
+		if a.err != nil {
+			delete(gn.assets.assets, name)
+		}
+	}
+}
+
+// AddAsset decodes a PNG or JPEG image and stores it as the asset with the
+// name, replacing the one loaded before.
+func (gn *Generator) AddAsset(name string, in io.Reader) error {
+	if !ValidAssetName(name) {
+		return fmt.Errorf("wrong asset name `%s`", name)
+	}
+
+	img, err := decodeAsset(in)
+
+	if err != nil {
+		return err
+	}
+
+	defer gn.assets.m.Unlock()
+
+	gn.assets.m.Lock()
+
+	gn.assets.put(name, img)
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package painter
+
+import "image"
+import "image/color"
+import "image/draw"
+import "math"
+import "sort"
+import "golang.org/x/image/vector"
+
+// fillPolygons fills the polygons given by their vertices in canvas
+// coordinates, edges are smooth on antialiased canvases.
+func fillPolygons(c Canvas, vp Viewport, polygons [][]Point, src color.RGBA, op draw.Op) {
+	if antialiased(c) {
+		fillPolygonsAA(c, vp, polygons, src)
+		return
+	}
+
+	for _, polygon := range polygons {
+		for _, span := range polygonSpans(c, vp, polygon) {
+			fill(c, span, src, op)
+		}
+	}
+}
+
+// polygonSpans returns the pixels of the canvas inside of the polygon.
+// Canvases only fill rectangles, so an arbitrary polygon is split into
+// spans, one row of pixels at a time, covering pixels with centers inside.
+func polygonSpans(c Canvas, vp Viewport, polygon []Point) (spans []image.Rectangle) {
+	if len(polygon) < 3 {
+		return nil
+	}
+
+	if r, ok := axisAlignedRectangle(polygon); ok {
+		if span := vp.ToImageRect(r).Intersect(c.Bounds()); !span.Empty() {
+			spans = append(spans, span)
+		}
+		return
+	}
+
+	pixels := make([]Point, len(polygon))
+	minY, maxY := math.Inf(1), math.Inf(-1)
+
+	for i, p := range polygon {
+		pixels[i] = vp.toPixel(p)
+		minY = math.Min(minY, pixels[i].Y)
+		maxY = math.Max(maxY, pixels[i].Y)
+	}
+
+	bounds := c.Bounds()
+	top := max(int(math.Floor(minY)), bounds.Min.Y)
+	bottom := min(int(math.Ceil(maxY)), bounds.Max.Y)
+
+	crossings := []float64{}
+
+	for y := top; y < bottom; y++ {
+		sy := float64(y) + 0.5
+		crossings = crossings[:0]
+
+		for i, a := range pixels {
+			b := pixels[(i+1)%len(pixels)]
+
+			if (a.Y <= sy && sy < b.Y) || (b.Y <= sy && sy < a.Y) {
+				crossings = append(crossings, a.X+(sy-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+			}
+		}
+
+		sort.Float64s(crossings)
+
+		for i := 0; i+1 < len(crossings); i += 2 {
+			x0 := int(math.Ceil(crossings[i] - 0.5))
+			x1 := int(math.Ceil(crossings[i+1] - 0.5))
+
+			span := image.Rect(x0, y, x1, y+1).Intersect(bounds)
+
+			if !span.Empty() {
+				spans = append(spans, span)
+			}
+		}
+	}
+
+	return
+}
+
+// axisAlignedRectangle tells whether the polygon is a rectangle with sides
+// parallel to the axes and returns it.
+func axisAlignedRectangle(polygon []Point) (Rectangle, bool) {
+	if len(polygon) != 4 {
+		return Rectangle{}, false
+	}
+
+	r := Rectangle{Min: polygon[0], Max: polygon[0]}
+	for _, p := range polygon[1:] {
+		r = r.Union(Rectangle{Min: p, Max: p})
+	}
+
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+
+		if a.X != b.X && a.Y != b.Y {
+			return Rectangle{}, false
+		}
+	}
+
+	return r, true
+}
+
+// Polygon returns the corners of r in drawing order.
+func (r Rectangle) Polygon() []Point {
+	return []Point{r.Min, {X: r.Max.X, Y: r.Min.Y}, r.Max, {X: r.Min.X, Y: r.Max.Y}}
+}
+
+// fillPolygonsAA fills the polygons blending their edges with the canvas.
+// Overlapping parts are filled once.
+func fillPolygonsAA(c Canvas, vp Viewport, polygons [][]Point, src color.RGBA) {
+	if mask := polygonMask(c, vp, polygons); mask != nil {
+		fillMask(c, mask, src)
+	}
+}
+
+// polygonMask returns the coverage of the canvas pixels by the polygons,
+// or nil when they are not visible.
+func polygonMask(c Canvas, vp Viewport, polygons [][]Point) *image.Alpha {
+	pixels := make([][]Point, 0, len(polygons))
+	bounds := Rectangle{Min: Point{X: math.Inf(1), Y: math.Inf(1)}, Max: Point{X: math.Inf(-1), Y: math.Inf(-1)}}
+
+	for _, polygon := range polygons {
+		if len(polygon) < 3 {
+			continue
+		}
+
+		pixel := make([]Point, len(polygon))
+
+		for i, p := range polygon {
+			pixel[i] = vp.toPixel(p)
+			bounds = bounds.Union(Rectangle{Min: pixel[i], Max: pixel[i]})
+		}
+
+		// the rasterizer sums up signed areas, so polygons turning the other
+		// way would cut holes where they overlap
+		if signedArea(pixel) < 0 {
+			for i, j := 0, len(pixel)-1; i < j; i, j = i+1, j-1 {
+				pixel[i], pixel[j] = pixel[j], pixel[i]
+			}
+		}
+
+		pixels = append(pixels, pixel)
+	}
+
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	area := image.Rect(
+		int(math.Floor(bounds.Min.X)), int(math.Floor(bounds.Min.Y)),
+		int(math.Ceil(bounds.Max.X)), int(math.Ceil(bounds.Max.Y)),
+	).Intersect(c.Bounds())
+
+	if area.Empty() {
+		return nil
+	}
+
+	origin := Point{X: float64(area.Min.X), Y: float64(area.Min.Y)}
+	z := vector.NewRasterizer(area.Dx(), area.Dy())
+
+	for _, pixel := range pixels {
+		z.MoveTo(float32(pixel[0].X-origin.X), float32(pixel[0].Y-origin.Y))
+
+		for _, p := range pixel[1:] {
+			z.LineTo(float32(p.X-origin.X), float32(p.Y-origin.Y))
+		}
+
+		z.ClosePath()
+	}
+
+	mask := image.NewAlpha(area)
+	z.Draw(mask, area, image.Opaque, image.Point{})
+
+	return mask
+}
+
+func signedArea(polygon []Point) (area float64) {
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+
+	return area / 2
+}
@@ -0,0 +1,73 @@
+package painter
+
+// Shape is an element of the scene that has an id, can be pointed at and
+// moved around the canvas.
+type Shape interface {
+	DrawableElement
+
+	GetID() int
+	setID(id int)
+
+	// clone returns a copy of the shape, which can be read while the
+	// shape itself is changed.
+	clone() Shape
+
+	Bounds() Rectangle
+	Contains(p Point) bool
+	Move(v Point)
+}
+
+func (tf *TFigure) GetID() int {
+	return tf.ID
+}
+
+func (tf *TFigure) setID(id int) {
+	tf.ID = id
+}
+
+func (tf *TFigure) clone() Shape {
+	c := *tf
+	return &c
+}
+
+func (tf *TFigure) setStroke(s Stroke) {
+	tf.Stroke = s
+}
+
+func (tf *TFigure) setGradient(g *Gradient) {
+	tf.Gradient = g
+}
+
+func (txt *Text) GetID() int {
+	return txt.ID
+}
+
+func (txt *Text) setID(id int) {
+	txt.ID = id
+}
+
+func (txt *Text) clone() Shape {
+	c := *txt
+	return &c
+}
+
+func (brect *BRect) GetID() int {
+	return brect.ID
+}
+
+func (brect *BRect) setID(id int) {
+	brect.ID = id
+}
+
+func (brect *BRect) clone() Shape {
+	c := *brect
+	return &c
+}
+
+func (brect *BRect) setStroke(s Stroke) {
+	brect.Stroke = s
+}
+
+func (brect *BRect) setGradient(g *Gradient) {
+	brect.Gradient = g
+}
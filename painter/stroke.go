@@ -0,0 +1,255 @@
+package painter
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+var StrokeColor = color.RGBA{A: 0xff}
+
+// MaxDashes limits the amount of lengths in a dash pattern.
+const MaxDashes = 8
+
+// Joins of strokes are drawn as polygons with this amount of sides.
+const strokeJoinSides = 16
+
+// Stroke outlines a shape, Width and the Dash lengths are in canvas units.
+// The dash pattern alternates drawn and skipped lengths, a zero width
+// stroke is not drawn.
+type Stroke struct {
+	Color color.RGBA
+	Width float64
+	Dash  []float64
+}
+
+func (s Stroke) String() string {
+	str := formatFloat(s.Width)
+
+	for _, length := range s.Dash {
+		str += " " + formatFloat(length)
+	}
+
+	if s.Color != StrokeColor {
+		str += " " + formatColor(s.Color)
+	}
+
+	return str
+}
+
+// runs splits the path into the pieces drawn with the dash pattern.
+func (s Stroke) runs(path []Point, closed bool) [][]Point {
+	if closed && len(path) > 0 {
+		path = append(path[:len(path):len(path)], path[0])
+	}
+
+	if len(s.Dash) == 0 {
+		return [][]Point{path}
+	}
+
+	pattern := s.Dash
+	if len(pattern)%2 == 1 {
+		pattern = append(pattern[:len(pattern):len(pattern)], pattern...)
+	}
+
+	runs := [][]Point{}
+	run := []Point{}
+	dash, left, on := 0, pattern[0], true
+
+	for i := 0; i+1 < len(path); i++ {
+		a, b := path[i], path[i+1]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		pos := 0.0
+
+		if on && len(run) == 0 {
+			run = append(run, a)
+		}
+
+		for length-pos > left {
+			pos += left
+			p := Point{X: a.X + (b.X-a.X)*pos/length, Y: a.Y + (b.Y-a.Y)*pos/length}
+
+			if on {
+				runs = append(runs, append(run, p))
+				run = []Point{}
+			} else {
+				run = []Point{p}
+			}
+
+			on = !on
+			dash = (dash + 1) % len(pattern)
+			left = pattern[dash]
+		}
+
+		left -= length - pos
+
+		if on {
+			run = append(run, b)
+		}
+	}
+
+	if on && len(run) > 1 {
+		runs = append(runs, run)
+	}
+
+	return runs
+}
+
+// polygons covers the path stroked with the width: segments become quads
+// and the points where they meet are rounded.
+func (s Stroke) polygons(path []Point, closed bool) [][]Point {
+	polygons := [][]Point{}
+	half := s.Width / 2
+
+	for _, run := range s.runs(path, closed) {
+		for i := 0; i+1 < len(run); i++ {
+			a, b := run[i], run[i+1]
+			length := math.Hypot(b.X-a.X, b.Y-a.Y)
+
+			if length == 0 {
+				continue
+			}
+
+			n := Point{X: (a.Y - b.Y) / length * half, Y: (b.X - a.X) / length * half}
+
+			polygons = append(polygons, []Point{
+				{X: a.X + n.X, Y: a.Y + n.Y}, {X: b.X + n.X, Y: b.Y + n.Y},
+				{X: b.X - n.X, Y: b.Y - n.Y}, {X: a.X - n.X, Y: a.Y - n.Y},
+			})
+		}
+
+		joined := run[1 : len(run)-1]
+		if closed && len(s.Dash) == 0 {
+			joined = run
+		}
+
+		for _, p := range joined {
+			join := make([]Point, strokeJoinSides)
+
+			for i := range join {
+				angle := 2 * math.Pi * float64(i) / strokeJoinSides
+				join[i] = Point{X: p.X + half*math.Cos(angle), Y: p.Y + half*math.Sin(angle)}
+			}
+
+			polygons = append(polygons, join)
+		}
+	}
+
+	return polygons
+}
+
+// Draw strokes the path given in canvas coordinates.
+func (s Stroke) Draw(c Canvas, vp Viewport, path []Point, closed bool) {
+	if s.Width <= 0 || len(path) < 2 {
+		return
+	}
+
+	fillPolygonsAA(c, vp, s.polygons(path, closed), s.Color)
+}
+
+// outlined is implemented by shapes able to have a stroke.
+type outlined interface {
+	setStroke(s Stroke)
+}
+
+// SetStroke outlines the shape with the ID. Figures, rectangles, pictures
+// and lines take strokes; the painter has no ellipse or polygon shapes, so
+// there are none to outline.
+type SetStroke struct {
+	ID     int
+	Stroke Stroke
+}
+
+func (ss SetStroke) String() string {
+	return "stroke " + strconv.Itoa(ss.ID) + " " + ss.Stroke.String()
+}
+
+func (ss SetStroke) MarshalText() ([]byte, error) {
+	return []byte(ss.String()), nil
+}
+
+func NewSetStroke(id int, width float64, dash []float64, c color.RGBA) SetStroke {
+	return SetStroke{ID: id, Stroke: Stroke{Color: c, Width: width, Dash: dash}}
+}
+
+// Line is a segment from From to To drawn with its stroke.
+type Line struct {
+	ID     int
+	From   Point
+	To     Point
+	Stroke Stroke
+}
+
+func (ln Line) String() string {
+	return "line " +
+		formatFloat(ln.From.X) + " " + formatFloat(ln.From.Y) + " " +
+		formatFloat(ln.To.X) + " " + formatFloat(ln.To.Y) + " " + ln.Stroke.String()
+}
+
+func (ln Line) MarshalText() ([]byte, error) {
+	return []byte(ln.String()), nil
+}
+
+func (ln *Line) GetID() int {
+	return ln.ID
+}
+
+func (ln *Line) setID(id int) {
+	ln.ID = id
+}
+
+func (ln *Line) clone() Shape {
+	c := *ln
+	return &c
+}
+
+func (ln *Line) setStroke(s Stroke) {
+	ln.Stroke = s
+}
+
+// Bounds returns the smallest rectangle containing the stroked line.
+func (ln *Line) Bounds() Rectangle {
+	half := ln.Stroke.Width / 2
+	bounds := Rectangle{Min: ln.From, Max: ln.From}.Union(Rectangle{Min: ln.To, Max: ln.To})
+
+	bounds.Min.X -= half
+	bounds.Min.Y -= half
+	bounds.Max.X += half
+	bounds.Max.Y += half
+
+	return bounds
+}
+
+// Contains tells whether p lies on the stroke of the line.
+func (ln *Line) Contains(p Point) bool {
+	d := Point{X: ln.To.X - ln.From.X, Y: ln.To.Y - ln.From.Y}
+	t := 0.0
+
+	if l := d.X*d.X + d.Y*d.Y; l > 0 {
+		t = math.Min(math.Max(((p.X-ln.From.X)*d.X+(p.Y-ln.From.Y)*d.Y)/l, 0), 1)
+	}
+
+	closest := Point{X: ln.From.X + d.X*t, Y: ln.From.Y + d.Y*t}
+
+	return math.Hypot(p.X-closest.X, p.Y-closest.Y) <= ln.Stroke.Width/2
+}
+
+func (ln *Line) Move(v Point) {
+	ln.From.X += v.X
+	ln.From.Y += v.Y
+	ln.To.X += v.X
+	ln.To.Y += v.Y
+}
+
+// MoveTo puts the start of the line at p keeping its direction and length.
+func (ln *Line) Draw(c Canvas, vp Viewport) {
+	ln.Stroke.Draw(c, vp, []Point{ln.From, ln.To}, false)
+}
+
+func NewLine(x1, y1, x2, y2, width float64, dash []float64, c color.RGBA) Line {
+	return Line{
+		From:   Point{X: x1, Y: y1},
+		To:     Point{X: x2, Y: y2},
+		Stroke: Stroke{Color: c, Width: width, Dash: dash},
+	}
+}
@@ -0,0 +1,163 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var TextColor = color.RGBA{A: 0xff}
+
+// TextSize is the default size of the font in canvas units.
+const TextSize = 0.05
+
+// Labels are rendered at most at this size in pixels and scaled up when
+// they are bigger, which keeps zoomed in text cheap.
+const maxGlyphSize = 256
+
+// Text is a single line label with the top-left corner at Position.
+type Text struct {
+	ID       int
+	Label    string
+	Position Point
+	Size     float64
+	Color    color.RGBA
+}
+
+func (txt Text) String() string {
+	s := "text " + formatFloat(txt.Position.X) + " " + formatFloat(txt.Position.Y) + " " + strconv.Quote(txt.Label)
+
+	if txt.Size != TextSize {
+		s += " " + formatFloat(txt.Size)
+	}
+
+	if txt.Color != TextColor {
+		s += " " + formatColor(txt.Color)
+	}
+
+	return s
+}
+
+func (txt Text) MarshalText() ([]byte, error) {
+	return []byte(txt.String()), nil
+}
+
+// Bounds returns the rectangle of the label, which stretches together with
+// the canvas the same way figures do.
+func (txt *Text) Bounds() Rectangle {
+	width, height := measureLabel(txt.Label)
+
+	return Rectangle{
+		Min: txt.Position,
+		Max: Point{X: txt.Position.X + width*txt.Size, Y: txt.Position.Y + height*txt.Size},
+	}
+}
+
+func (txt *Text) Contains(p Point) bool {
+	return txt.Bounds().Contains(p)
+}
+
+func (txt *Text) Move(v Point) {
+	txt.Position.X += v.X
+	txt.Position.Y += v.Y
+}
+
+func (txt *Text) Draw(c Canvas, vp Viewport) {
+	dr := vp.ToImageRect(txt.Bounds())
+	visible := dr.Intersect(c.Bounds())
+
+	if visible.Empty() {
+		return
+	}
+
+	glyphs := renderLabel(txt.Label, min(dr.Dy(), maxGlyphSize))
+
+	mask := image.NewAlpha(visible)
+	draw.ApproxBiLinear.Scale(mask, dr, glyphs, glyphs.Bounds(), screen.Src, nil)
+
+	fillMask(c, mask, txt.Color)
+}
+
+func NewText(x, y float64, label string, size float64, c color.RGBA) Text {
+	return Text{
+		Label:    label,
+		Position: Point{X: x, Y: y},
+		Size:     size,
+		Color:    c,
+	}
+}
+
+var fonts = struct {
+	sync.Mutex
+	regular *opentype.Font
+	faces   map[int]font.Face
+}{faces: map[int]font.Face{}}
+
+// face returns the regular Go font of the size in pixels.
+func face(size int) font.Face {
+	if fonts.regular == nil {
+		fonts.regular, _ = opentype.Parse(goregular.TTF)
+	}
+
+	if f, ok := fonts.faces[size]; ok {
+		return f
+	}
+
+	f, _ := opentype.NewFace(fonts.regular, &opentype.FaceOptions{Size: float64(size), DPI: 72})
+	fonts.faces[size] = f
+
+	return f
+}
+
+// measureLabel returns the size of the label set in a font of size one.
+func measureLabel(label string) (width, height float64) {
+	defer fonts.Unlock()
+
+	fonts.Lock()
+
+	f := face(maxGlyphSize)
+	metrics := f.Metrics()
+
+	width = float64(font.MeasureString(f, label)) / 64 / maxGlyphSize
+	height = float64(metrics.Ascent+metrics.Descent) / 64 / maxGlyphSize
+
+	return
+}
+
+// renderLabel sets the label into a coverage mask, which is height pixels
+// tall.
+func renderLabel(label string, height int) *image.Alpha {
+	defer fonts.Unlock()
+
+	fonts.Lock()
+
+	// the font size giving a line of the height
+	size := max(1, int(float64(height)*float64(maxGlyphSize)/lineHeight(face(maxGlyphSize))))
+	f := face(size)
+
+	width := font.MeasureString(f, label).Ceil()
+	mask := image.NewAlpha(image.Rect(0, 0, max(width, 1), max(height, 1)))
+
+	d := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: f,
+		Dot:  fixed.Point26_6{Y: f.Metrics().Ascent},
+	}
+	d.DrawString(label)
+
+	return mask
+}
+
+func lineHeight(f font.Face) float64 {
+	metrics := f.Metrics()
+	return float64(metrics.Ascent+metrics.Descent) / 64
+}
@@ -0,0 +1,163 @@
+package painter
+
+import "math"
+import "strconv"
+
+// Limits of the arguments of moves and transforms written as commands.
+const (
+	MaxOffset  = 1.0
+	MaxDegrees = 360.0
+	MaxFactor  = 100.0
+)
+
+// Affine is the map (x, y) -> (A*x + C*y + E, B*x + D*y + F), its
+// coefficients go in the same order as in SVG's matrix(a, b, c, d, e, f).
+type Affine struct {
+	A, B, C, D, E, F float64
+}
+
+func Identity() Affine {
+	return Affine{A: 1, D: 1}
+}
+
+func Translation(v Point) Affine {
+	return Affine{A: 1, D: 1, E: v.X, F: v.Y}
+}
+
+// Rotation turns the plane by degrees clockwise on the screen around p.
+func Rotation(degrees float64, p Point) Affine {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	rotation := Affine{A: cos, B: sin, C: -sin, D: cos}
+
+	return Translation(p).Mul(rotation).Mul(Translation(Point{-p.X, -p.Y}))
+}
+
+// Scaling stretches the plane by s around p.
+func Scaling(s Point, p Point) Affine {
+	return Translation(p).Mul(Affine{A: s.X, D: s.Y}).Mul(Translation(Point{-p.X, -p.Y}))
+}
+
+func (m Affine) Apply(p Point) Point {
+	return Point{
+		X: m.A*p.X + m.C*p.Y + m.E,
+		Y: m.B*p.X + m.D*p.Y + m.F,
+	}
+}
+
+// Mul returns the map applying n first and m after it.
+func (m Affine) Mul(n Affine) Affine {
+	return Affine{
+		A: m.A*n.A + m.C*n.B,
+		B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D,
+		D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E,
+		F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+// Linear drops the translation part of m.
+func (m Affine) Linear() Affine {
+	m.E, m.F = 0, 0
+	return m
+}
+
+func (m Affine) Det() float64 {
+	return m.A*m.D - m.B*m.C
+}
+
+func (m Affine) Invert() (Affine, bool) {
+	det := m.Det()
+
+	if det == 0 {
+		return Affine{}, false
+	}
+
+	inv := Affine{A: m.D / det, B: -m.B / det, C: -m.C / det, D: m.A / det}
+	offset := inv.Apply(Point{m.E, m.F})
+	inv.E, inv.F = -offset.X, -offset.Y
+
+	return inv, true
+}
+
+// IsAxisAligned tells whether m keeps horizontal lines horizontal and
+// vertical lines vertical.
+func (m Affine) IsAxisAligned() bool {
+	return m.B == 0 && m.C == 0
+}
+
+type Rotate struct {
+	ID      int
+	Degrees float64
+	Around  *Point
+}
+
+func (r Rotate) String() string {
+	s := "rotate " + strconv.Itoa(r.ID) + " " + formatFloat(r.Degrees)
+
+	if r.Around != nil {
+		s += " " + formatFloat(r.Around.X) + " " + formatFloat(r.Around.Y)
+	}
+
+	return s
+}
+
+func (r Rotate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// NewRotate turns the figure with the id around the point, or around its own
+// center when around is nil.
+func NewRotate(id int, degrees float64, around *Point) Rotate {
+	return Rotate{ID: id, Degrees: degrees, Around: around}
+}
+
+type Scale struct {
+	ID     int
+	Factor Point
+	Around *Point
+}
+
+func (s Scale) String() string {
+	text := "scale " + strconv.Itoa(s.ID) + " " + formatFloat(s.Factor.X) + " " + formatFloat(s.Factor.Y)
+
+	if s.Around != nil {
+		text += " " + formatFloat(s.Around.X) + " " + formatFloat(s.Around.Y)
+	}
+
+	return text
+}
+
+func (s Scale) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// NewScale stretches the figure with the id around the point, or around its
+// own center when around is nil.
+func NewScale(id int, sx, sy float64, around *Point) Scale {
+	return Scale{ID: id, Factor: Point{X: sx, Y: sy}, Around: around}
+}
+
+type Transform struct {
+	ID     int
+	Matrix Affine
+}
+
+func (t Transform) String() string {
+	m := t.Matrix
+	s := "transform " + strconv.Itoa(t.ID)
+
+	for _, v := range []float64{m.A, m.B, m.C, m.D, m.E, m.F} {
+		s += " " + formatFloat(v)
+	}
+
+	return s
+}
+
+func (t Transform) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func NewTransform(id int, m Affine) Transform {
+	return Transform{ID: id, Matrix: m}
+}
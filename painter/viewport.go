@@ -0,0 +1,83 @@
+package painter
+
+import "image"
+import "math"
+
+// Viewport maps canvas coordinates, where the canvas spans [0, 1] on both
+// axes, to pixels of an output image and back, looking through the Camera.
+type Viewport struct {
+	Bounds image.Rectangle
+	Camera Camera
+}
+
+func NewViewport(bounds image.Rectangle) Viewport {
+	return Viewport{Bounds: bounds}
+}
+
+func (vp Viewport) scale() Point {
+	size := vp.Bounds.Size()
+	zoom := vp.Camera.zoom()
+	return Point{X: float64(size.X) * zoom, Y: float64(size.Y) * zoom}
+}
+
+// toPixel maps p to the image without rounding to whole pixels.
+func (vp Viewport) toPixel(p Point) Point {
+	scale := vp.scale()
+	offset := vp.Camera.Offset
+
+	return Point{
+		X: float64(vp.Bounds.Min.X) + scale.X*(p.X-offset.X),
+		Y: float64(vp.Bounds.Min.Y) + scale.Y*(p.Y-offset.Y),
+	}
+}
+
+func (vp Viewport) ToImage(p Point) image.Point {
+	pixel := vp.toPixel(p)
+
+	return image.Point{
+		X: int(math.Floor(pixel.X)),
+		Y: int(math.Floor(pixel.Y)),
+	}
+}
+
+func (vp Viewport) ToImageRect(r Rectangle) image.Rectangle {
+	return image.Rectangle{Min: vp.ToImage(r.Min), Max: vp.ToImage(r.Max)}
+}
+
+func (vp Viewport) ToCanvas(p image.Point) Point {
+	scale := vp.scale()
+
+	if scale.X == 0 || scale.Y == 0 {
+		return Point{}
+	}
+
+	offset := vp.Camera.Offset
+
+	return Point{
+		X: offset.X + float64(p.X-vp.Bounds.Min.X)/scale.X,
+		Y: offset.Y + float64(p.Y-vp.Bounds.Min.Y)/scale.Y,
+	}
+}
+
+// ToViewVector converts a vector in pixels to fractions of the view.
+func (vp Viewport) ToViewVector(v image.Point) Point {
+	size := vp.Bounds.Size()
+
+	if size.X == 0 || size.Y == 0 {
+		return Point{}
+	}
+
+	return Point{X: float64(v.X) / float64(size.X), Y: float64(v.Y) / float64(size.Y)}
+}
+
+// PixelSize returns the size of a pixel in canvas units, which is the
+// smaller of its sides when pixels are not square on the canvas.
+func (vp Viewport) PixelSize() float64 {
+	scale := vp.scale()
+
+	if scale.X == 0 || scale.Y == 0 {
+		return 0
+	}
+
+	return 1 / math.Max(scale.X, scale.Y)
+}
@@ -22,6 +22,7 @@ type Visualizer struct {
 	StopLoop      func()
 	GetTexture    func(p image.Point) (screen.Texture, error)
 	HandleClick   func(e mouse.Event) bool
+	HandleKey     func(e key.Event) bool
 
 	w    screen.Window
 	done chan struct{}
@@ -89,10 +90,6 @@ func detectTerminate(e any) bool {
 		if e.To == lifecycle.StageDead {
 			return true // Window destroy initiated.
 		}
-	case key.Event:
-		if e.Code == key.CodeEscape {
-			return true // Esc pressed.
-		}
 	}
 	return false
 }
@@ -113,6 +110,17 @@ func (pw *Visualizer) handleEvent(e any) {
 			pw.w.Send(paint.Event{})
 		}
 
+	case key.Event:
+		if pw.HandleKey != nil && pw.HandleKey(e) {
+			pw.w.Send(paint.Event{})
+			return
+		}
+
+		// Esc closes the window unless it was taken by the key handler.
+		if e.Code == key.CodeEscape && e.Direction == key.DirPress {
+			pw.w.Send(lifecycle.Event{To: lifecycle.StageDead})
+		}
+
 	case paint.Event:
 		t, err := pw.GetTexture(pw.sz.Size())
 
@@ -130,6 +138,11 @@ func (pw *Visualizer) handleEvent(e any) {
 	}
 }
 
+// Size returns the current size of the window.
+func (pw *Visualizer) Size() image.Point {
+	return pw.sz.Size()
+}
+
 func (pw *Visualizer) Update() {
 	pw.w.Send(paint.Event{})
 }